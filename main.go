@@ -14,17 +14,33 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.mpi-internal.com/spt-security/vulcan-local/pkg/cmd"
 	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/generator"
 	"github.mpi-internal.com/spt-security/vulcan-local/pkg/reporting"
 )
 
 const envDefaultChecktypesUri = "VULCAN_CHECKTYPES_URI"
 
-func main() {
-	exitCode := 1
-	defer os.Exit(exitCode)
+type command struct {
+	name        string
+	description string
+	run         func(args []string, log *logrus.Logger) int
+}
+
+var commands []command
 
-	var err error
+func init() {
+	commands = []command{
+		{"scan", "run the checks (default command)", runScan},
+		{"checktypes list", "list the checktypes available in the repositories", runChecktypesList},
+		{"checktypes show", "show the details of a checktype", runChecktypesShow},
+		{"config validate", "validate the config file", runConfigValidate},
+		{"report render", "generate the report from a previous json or vulcan-local results file", runReportRender},
+		{"diff", "compare two json results files", runDiff},
+		{"version", "print the version", runVersion},
+	}
+}
 
+func main() {
 	var log = logrus.New()
 	log.SetFormatter(&logrus.TextFormatter{
 		DisableColors:   false,
@@ -33,7 +49,37 @@ func main() {
 		ForceColors:     true,
 	})
 
-	cfg := &config.Config{
+	os.Exit(run(os.Args[1:], log))
+}
+
+// run executes the command in args. When the first argument is a flag the
+// scan command is executed to keep compatibility (i.e. vulcan-local -c vulcan.yaml).
+func run(args []string, log *logrus.Logger) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runScan(args, log)
+	}
+	for _, c := range commands {
+		words := strings.Fields(c.name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == c.name {
+			return c.run(args[len(words):], log)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %s\n", strings.Join(args, " "))
+	usage()
+	return reporting.ErrorExitCode
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-18s%s\n", c.name, c.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nUse \"%s [command] -h\" for more information about a command.\n", os.Args[0])
+}
+
+// newConfig returns the config with the default values.
+func newConfig() *config.Config {
+	return &config.Config{
 		Conf: config.Conf{
 			PullPolicy:  "IfNotPresent",
 			DockerBin:   "docker",
@@ -50,31 +96,54 @@ func main() {
 		CheckTypes: map[config.ChecktypeRef]config.Checktype{},
 		Checks:     []config.Check{},
 	}
+}
 
-	var help bool
-	var configFile, targetOptions string
-	cmdTarget := config.Target{}
-	flag.BoolVar(&help, "h", false, "print usage")
-	flag.StringVar(&configFile, "c", "", "config file (i.e. -c vulcan.yaml)")
-	flag.StringVar(&cfg.Conf.LogLevel, "l", cfg.Conf.LogLevel, "log level (panic, fatal, error, warn, info, debug)")
-	flag.StringVar(&cfg.Reporting.OutputFile, "r", "", "results file (i.e. -r results.json)")
-	flag.StringVar(&cfg.Conf.Include, "i", cfg.Conf.Include, "include checktype regex")
-	flag.StringVar(&cfg.Conf.Exclude, "e", cfg.Conf.Exclude, "exclude checktype regex")
-	flag.StringVar(&cmdTarget.Target, "t", "", "target to check")
-	flag.StringVar(&targetOptions, "o", "", `options related to the target (-t) used in all the their checks (i.e. '{"depth":"1", "max_scan_duration": 1}')`)
-	flag.StringVar(&cmdTarget.AssetType, "a", "", "asset type (WebAddress, ...)")
-	flag.StringVar(&cfg.Reporting.Threshold, "s", cfg.Reporting.Threshold, fmt.Sprintf("filter by severity (%v)", strings.Join(reporting.SeverityNames(), ", ")))
-	flag.StringVar(&cfg.Conf.Repository, "u", "", fmt.Sprintf("chektypes uri (or %s)", envDefaultChecktypesUri))
-	flag.StringVar(&cfg.Conf.DockerBin, cfg.Conf.DockerBin, cfg.Conf.DockerBin, "docker binary")
-	flag.StringVar(&cfg.Conf.GitBin, cfg.Conf.GitBin, cfg.Conf.GitBin, "git binary")
-	flag.StringVar(&cfg.Conf.IfName, "ifname", cfg.Conf.IfName, "network interface where agent will be available for the checks")
-	flag.IntVar(&cfg.Conf.Concurrency, "concurrency", cfg.Conf.Concurrency, "max number of checks/containers to run concurrently")
-	flag.Parse()
+func newFlagSet(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s %s:\n", os.Args[0], strings.TrimSpace(name+" [flags] "+arguments))
+		fs.PrintDefaults()
+	}
+	return fs
+}
 
-	if help {
-		flag.Usage()
-		return
+// stringsFlag is a flag that can be repeated.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// addConfigFlags adds the flags required to load the config.
+func addConfigFlags(fs *flag.FlagSet, cfg *config.Config, configFiles *stringsFlag) {
+	fs.Var(configFiles, "c", "config file, can be repeated and the last ones override the previous (i.e. -c base.yaml -c vulcan.yaml)")
+	fs.StringVar(&cfg.Conf.LogLevel, "l", cfg.Conf.LogLevel, "log level (panic, fatal, error, warn, info, debug)")
+	fs.StringVar(&cfg.Conf.Repository, "u", "", fmt.Sprintf("chektypes uri (or %s)", envDefaultChecktypesUri))
+}
+
+// parseFlags parses the flags allowing them to be placed after the positional
+// arguments, which are returned.
+func parseFlags(fs *flag.FlagSet, args []string) []string {
+	positional := []string{}
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
 	}
+}
+
+// loadConfig parses the flags, reads the config files and overwrites the
+// config values with the command line flags. Returns the positional arguments.
+func loadConfig(fs *flag.FlagSet, args []string, cfg *config.Config, configFiles *stringsFlag, log *logrus.Logger) ([]string, error) {
+	positional := parseFlagsOnce(fs, args)
 
 	if cfg.Conf.Repository == "" {
 		if repo := os.Getenv(envDefaultChecktypesUri); repo != "" {
@@ -82,33 +151,247 @@ func main() {
 		}
 	}
 
-	if configFile != "" {
-		err = config.ReadConfig(configFile, cfg, log)
-		if err != nil {
-			log.Errorf("Unable to parse config file %s %+v", configFile, err)
-			return
+	if len(*configFiles) > 0 {
+		for _, f := range *configFiles {
+			if err := config.ReadConfig(f, cfg, log); err != nil {
+				return nil, fmt.Errorf("unable to parse config file %s %+v", f, err)
+			}
 		}
 		// Overwrite the yaml config with the command line flags.
-		flag.Parse()
+		parseFlagsOnce(fs, args)
+	}
+	return positional, nil
+}
+
+// parseFlagsOnce parses the flags resetting the repeatable ones, as they are
+// appended on each parse.
+func parseFlagsOnce(fs *flag.FlagSet, args []string) []string {
+	fs.VisitAll(func(f *flag.Flag) {
+		if v, ok := f.Value.(*stringsFlag); ok {
+			*v = nil
+		}
+	})
+	return parseFlags(fs, args)
+}
+
+// addOutputFlag adds the repeatable -r flag.
+func addOutputFlag(fs *flag.FlagSet, outputs *stringsFlag, example string) {
+	fs.Var(outputs, "r", fmt.Sprintf("results file, with an optional format, can be repeated (i.e. -r %s -r sarif=results.sarif)", example))
+}
+
+// applyOutputFlags replaces the outputs of the config with the ones in the
+// -r flags, if any.
+func applyOutputFlags(cfg *config.Config, outputs stringsFlag) {
+	if len(outputs) == 0 {
+		return
+	}
+	cfg.Reporting.OutputFile = ""
+	cfg.Reporting.Outputs = nil
+	for _, o := range outputs {
+		cfg.Reporting.Outputs = append(cfg.Reporting.Outputs, config.ParseOutput(o))
+	}
+}
+
+func runScan(args []string, log *logrus.Logger) int {
+	cfg := newConfig()
+	var help bool
+	var targetOptions string
+	var configFiles, outputs stringsFlag
+	cmdTarget := config.Target{}
+	fs := newFlagSet("scan", "")
+	fs.Usage = func() {
+		usage()
+		fmt.Fprintf(fs.Output(), "\nUsage of %s scan [flags]:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&help, "h", false, "print usage")
+	addConfigFlags(fs, cfg, &configFiles)
+	addOutputFlag(fs, &outputs, "results.json")
+	fs.BoolVar(&cfg.Reporting.Unfiltered, "unfiltered", cfg.Reporting.Unfiltered, "write the excluded and below threshold vulnerabilities in the results files")
+	fs.StringVar(&cfg.Reporting.Baseline, "b", "", "baseline json results file, only new vulnerabilities are reported (i.e. -b baseline.json)")
+	fs.StringVar(&cfg.Reporting.LogsDir, "logs-dir", cfg.Reporting.LogsDir, "directory to write the logs of each check")
+	fs.StringVar(&cfg.Conf.Include, "i", cfg.Conf.Include, "include checktype regex")
+	fs.StringVar(&cfg.Conf.Exclude, "e", cfg.Conf.Exclude, "exclude checktype regex")
+	fs.StringVar(&cmdTarget.Target, "t", "", "target to check")
+	fs.StringVar(&targetOptions, "o", "", `options related to the target (-t) used in all the their checks (i.e. '{"depth":"1", "max_scan_duration": 1}')`)
+	fs.StringVar(&cmdTarget.AssetType, "a", "", "asset type (WebAddress, ...)")
+	fs.StringVar(&cfg.Reporting.Threshold, "s", cfg.Reporting.Threshold, fmt.Sprintf("filter by severity (%v)", strings.Join(reporting.SeverityNames(), ", ")))
+	fs.StringVar(&cfg.Conf.DockerBin, cfg.Conf.DockerBin, cfg.Conf.DockerBin, "docker binary")
+	fs.StringVar(&cfg.Conf.GitBin, cfg.Conf.GitBin, cfg.Conf.GitBin, "git binary")
+	fs.StringVar(&cfg.Conf.IfName, "ifname", cfg.Conf.IfName, "network interface where agent will be available for the checks")
+	fs.IntVar(&cfg.Conf.Concurrency, "concurrency", cfg.Conf.Concurrency, "max number of checks/containers to run concurrently")
+	fs.BoolVar(&cfg.Conf.DryRun, "dry-run", false, "print the checks to run without running them")
+	fs.StringVar(&cfg.Conf.DryRunFormat, "dry-run-format", "table", fmt.Sprintf("dry run output format (%v)", strings.Join(cmd.DryRunFormats, ", ")))
+
+	fs.Parse(args)
+	if help {
+		fs.Usage()
+		return reporting.SuccessExitCode
 	}
 
+	// Checked before importing the repositories and starting the git servers.
+	if !generator.StringInSlice(cfg.Conf.DryRunFormat, cmd.DryRunFormats) {
+		log.Errorf("Invalid dry run format %s, expected %s", cfg.Conf.DryRunFormat, strings.Join(cmd.DryRunFormats, ", "))
+		return reporting.ErrorExitCode
+	}
+
+	if _, err := loadConfig(fs, args, cfg, &configFiles, log); err != nil {
+		log.Error(err)
+		return reporting.ErrorExitCode
+	}
+	applyOutputFlags(cfg, outputs)
+
 	if cmdTarget.Target != "" {
 		if targetOptions != "" {
-			if err = json.Unmarshal([]byte(targetOptions), &cmdTarget.Options); err != nil {
+			if err := json.Unmarshal([]byte(targetOptions), &cmdTarget.Options); err != nil {
 				log.Errorf("unable to parse options %+v", err)
-				return
+				return reporting.ErrorExitCode
 			}
 		}
 		cfg.Targets = append(cfg.Targets, cmdTarget)
 	} else {
 		if targetOptions != "" {
 			log.Errorf("Options without target are not allowed")
-			return
+			return reporting.ErrorExitCode
 		}
 	}
-	exitCode, err = cmd.Run(cfg, log)
+	exitCode, err := cmd.Run(cfg, log)
 	if err != nil {
 		log.Print(err)
 	}
-	os.Exit(exitCode)
+	return exitCode
+}
+
+func runChecktypesList(args []string, log *logrus.Logger) int {
+	cfg := newConfig()
+	var configFiles stringsFlag
+	opts := cmd.ChecktypesOptions{}
+	fs := newFlagSet("checktypes list", "")
+	addConfigFlags(fs, cfg, &configFiles)
+	fs.StringVar(&cfg.Conf.Include, "i", cfg.Conf.Include, "include checktype regex")
+	fs.StringVar(&cfg.Conf.Exclude, "e", cfg.Conf.Exclude, "exclude checktype regex")
+	fs.StringVar(&opts.AssetType, "a", "", "asset type (WebAddress, ...)")
+	fs.BoolVar(&opts.JSON, "json", false, "print the checktypes as json")
+	if _, err := loadConfig(fs, args, cfg, &configFiles, log); err != nil {
+		log.Error(err)
+		return reporting.ErrorExitCode
+	}
+	exitCode, err := cmd.ListChecktypes(cfg, opts, os.Stdout, log)
+	if err != nil {
+		log.Error(err)
+	}
+	return exitCode
+}
+
+func runChecktypesShow(args []string, log *logrus.Logger) int {
+	cfg := newConfig()
+	var configFiles stringsFlag
+	var asJSON bool
+	fs := newFlagSet("checktypes show", "checktype")
+	addConfigFlags(fs, cfg, &configFiles)
+	fs.BoolVar(&asJSON, "json", false, "print the checktype as json")
+	args, err := loadConfig(fs, args, cfg, &configFiles, log)
+	if err != nil {
+		log.Error(err)
+		return reporting.ErrorExitCode
+	}
+	if len(args) != 1 {
+		fs.Usage()
+		return reporting.ErrorExitCode
+	}
+	exitCode, err := cmd.ShowChecktype(cfg, args[0], asJSON, os.Stdout, log)
+	if err != nil {
+		log.Error(err)
+	}
+	return exitCode
+}
+
+func runConfigValidate(args []string, log *logrus.Logger) int {
+	cfg := newConfig()
+	var configFiles stringsFlag
+	fs := newFlagSet("config validate", "")
+	addConfigFlags(fs, cfg, &configFiles)
+	if _, err := loadConfig(fs, args, cfg, &configFiles, log); err != nil {
+		log.Error(err)
+		return reporting.ErrorExitCode
+	}
+	exitCode, err := cmd.ValidateConfig(cfg, log)
+	if err != nil {
+		log.Error(err)
+	}
+	return exitCode
+}
+
+func runReportRender(args []string, log *logrus.Logger) int {
+	cfg := newConfig()
+	var configFiles, outputs stringsFlag
+	fs := newFlagSet("report render", "results.json")
+	addConfigFlags(fs, cfg, &configFiles)
+	addOutputFlag(fs, &outputs, "results.html")
+	fs.BoolVar(&cfg.Reporting.Unfiltered, "unfiltered", cfg.Reporting.Unfiltered, "write the excluded and below threshold vulnerabilities in the results files")
+	fs.StringVar(&cfg.Reporting.Format, "f", cfg.Reporting.Format, fmt.Sprintf("results file format (%v)", strings.Join(reporting.FormatNames(), ", ")))
+	fs.StringVar(&cfg.Reporting.Baseline, "b", "", "baseline json results file, only new vulnerabilities are reported (i.e. -b baseline.json)")
+	fs.StringVar(&cfg.Reporting.Threshold, "s", cfg.Reporting.Threshold, fmt.Sprintf("filter by severity (%v)", strings.Join(reporting.SeverityNames(), ", ")))
+	args, err := loadConfig(fs, args, cfg, &configFiles, log)
+	if err != nil {
+		log.Error(err)
+		return reporting.ErrorExitCode
+	}
+	applyOutputFlags(cfg, outputs)
+	if len(args) != 1 {
+		fs.Usage()
+		return reporting.ErrorExitCode
+	}
+	exitCode, err := cmd.RenderReport(cfg, args[0], log)
+	if err != nil {
+		log.Error(err)
+	}
+	return exitCode
+}
+
+// runDiff compares two json reports written with -r.
+func runDiff(args []string, log *logrus.Logger) int {
+	var asJSON bool
+	fs := newFlagSet("diff", "previous.json current.json")
+	fs.BoolVar(&asJSON, "json", false, "print the differences as json")
+	args = parseFlags(fs, args)
+	if len(args) != 2 {
+		fs.Usage()
+		return reporting.ErrorExitCode
+	}
+	previous, err := reporting.ReadReport(args[0])
+	if err != nil {
+		log.Errorf("Unable to read report %+v", err)
+		return reporting.ErrorExitCode
+	}
+	current, err := reporting.ReadReport(args[1])
+	if err != nil {
+		log.Errorf("Unable to read report %+v", err)
+		return reporting.ErrorExitCode
+	}
+	d := reporting.DiffReports(previous, current)
+	if asJSON {
+		if err := d.WriteJSON(os.Stdout); err != nil {
+			log.Errorf("Unable to write diff %+v", err)
+			return reporting.ErrorExitCode
+		}
+		return reporting.SuccessExitCode
+	}
+	d.Print(log)
+	return reporting.SuccessExitCode
+}
+
+func runVersion(args []string, log *logrus.Logger) int {
+	fs := newFlagSet("version", "")
+	fs.Parse(args)
+	fmt.Printf("vulcan-local %s", cmd.Version)
+	// Values set in the docker image.
+	if commit := os.Getenv("COMMIT"); commit != "" {
+		fmt.Printf(" commit=%s", commit)
+	}
+	if build := os.Getenv("BUILD_RFC3339"); build != "" {
+		fmt.Printf(" build=%s", build)
+	}
+	fmt.Println()
+	return reporting.SuccessExitCode
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/reporting"
+)
+
+func TestApplyOutputFlags(t *testing.T) {
+	configured := config.Reporting{
+		Format:     "sarif",
+		OutputFile: "config.sarif",
+		Outputs:    []config.Output{{Format: "junit", File: "config.xml"}},
+	}
+	tests := []struct {
+		name  string
+		flags stringsFlag
+		want  []config.Output
+	}{
+		{
+			name: "without flags",
+			want: []config.Output{{Format: "sarif", File: "config.sarif"}, {Format: "junit", File: "config.xml"}},
+		},
+		{
+			name:  "plain file",
+			flags: stringsFlag{"results.sarif"},
+			want:  []config.Output{{Format: "sarif", File: "results.sarif"}},
+		},
+		{
+			name:  "stdout",
+			flags: stringsFlag{"-"},
+			want:  []config.Output{{Format: "sarif", File: "-"}},
+		},
+		{
+			name:  "formats",
+			flags: stringsFlag{"json=results.json", "markdown=-", "out/a=b.sarif"},
+			want: []config.Output{
+				{Format: "json", File: "results.json"},
+				{Format: "markdown", File: "-"},
+				{Format: "sarif", File: "out/a=b.sarif"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Reporting: configured}
+			cfg.Reporting.Outputs = append([]config.Output{}, configured.Outputs...)
+			applyOutputFlags(cfg, tt.flags)
+			if got := cfg.Reporting.AllOutputs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputFlags(t *testing.T) {
+	var outputs stringsFlag
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	addOutputFlag(fs, &outputs, "results.json")
+	args := []string{"-r", "results.json", "report.json", "-r", "sarif=out/a=b.sarif"}
+	// The flags are parsed again after reading the config to override it.
+	for i := 0; i < 2; i++ {
+		positional := parseFlagsOnce(fs, args)
+		if want := []string{"report.json"}; !reflect.DeepEqual(positional, want) {
+			t.Fatalf("got positional %v, want %v", positional, want)
+		}
+	}
+	cfg := &config.Config{Reporting: config.Reporting{Format: "json"}}
+	applyOutputFlags(cfg, outputs)
+	want := []config.Output{{Format: "json", File: "results.json"}, {Format: "sarif", File: "out/a=b.sarif"}}
+	if got := cfg.Reporting.AllOutputs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRunScanInvalidDryRunFormat(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+	if got := runScan([]string{"-dry-run", "-dry-run-format", "yaml", "-t", "."}, log); got != reporting.ErrorExitCode {
+		t.Errorf("got exit code %d, want %d", got, reporting.ErrorExitCode)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	agentlog "github.com/adevinta/vulcan-agent/log"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/generator"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/reporting"
+)
+
+// ChecktypesOptions contains the filters and the output format used to list
+// the checktypes.
+type ChecktypesOptions struct {
+	AssetType string
+	JSON      bool
+}
+
+type checktypeEntry struct {
+	Ref config.ChecktypeRef `json:"ref"`
+	config.Checktype
+}
+
+// ListChecktypes prints the checktypes available in the configured
+// repositories that match the asset type and the include/exclude regexes.
+func ListChecktypes(cfg *config.Config, opts ChecktypesOptions, w io.Writer, log *logrus.Logger) (int, error) {
+	log.SetLevel(agentlog.ParseLogLevel(cfg.Conf.LogLevel))
+
+	if err := compileFilters(cfg); err != nil {
+		return reporting.ErrorExitCode, err
+	}
+
+	if err := generator.ImportRepositories(cfg, log); err != nil {
+		return reporting.ErrorExitCode, fmt.Errorf("unable to import repositories %+v", err)
+	}
+
+	entries := []checktypeEntry{}
+	for ref, ct := range cfg.CheckTypes {
+		if !generator.FilterChecktype(ct.Name, cfg.Conf.IncludeR, cfg.Conf.ExcludeR) {
+			continue
+		}
+		if opts.AssetType != "" && !generator.StringInSlice(opts.AssetType, ct.Assets) {
+			continue
+		}
+		entries = append(entries, checktypeEntry{Ref: ref, Checktype: ct})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Ref < entries[j].Ref
+	})
+
+	if opts.JSON {
+		if err := writeIndentedJSON(w, entries); err != nil {
+			return reporting.ErrorExitCode, err
+		}
+		return reporting.SuccessExitCode, nil
+	}
+	for _, e := range entries {
+		printChecktype(w, e, false)
+	}
+	log.Infof("Found %d checktypes", len(entries))
+	return reporting.SuccessExitCode, nil
+}
+
+// ShowChecktype prints the details of a checktype.
+func ShowChecktype(cfg *config.Config, ref string, asJSON bool, w io.Writer, log *logrus.Logger) (int, error) {
+	log.SetLevel(agentlog.ParseLogLevel(cfg.Conf.LogLevel))
+
+	if err := generator.ImportRepositories(cfg, log); err != nil {
+		return reporting.ErrorExitCode, fmt.Errorf("unable to import repositories %+v", err)
+	}
+
+	ct, err := generator.GetCheckType(cfg, config.ChecktypeRef(ref))
+	if err != nil {
+		return reporting.ErrorExitCode, err
+	}
+	if !strings.Contains(ref, "/") {
+		ref = "default/" + ref
+	}
+	e := checktypeEntry{Ref: config.ChecktypeRef(ref), Checktype: *ct}
+	if asJSON {
+		if err := writeIndentedJSON(w, e); err != nil {
+			return reporting.ErrorExitCode, err
+		}
+		return reporting.SuccessExitCode, nil
+	}
+	printChecktype(w, e, true)
+	return reporting.SuccessExitCode, nil
+}
+
+func printChecktype(w io.Writer, e checktypeEntry, details bool) {
+	timeout := "default"
+	if e.Timeout != 0 {
+		timeout = fmt.Sprintf("%ds", e.Timeout)
+	}
+	fmt.Fprintf(w, "%s\n", e.Ref)
+	if details && e.Description != "" {
+		fmt.Fprintf(w, "  %-15s%s\n", "description:", e.Description)
+	}
+	fmt.Fprintf(w, "  %-15s%s\n", "image:", e.Image)
+	fmt.Fprintf(w, "  %-15s%s\n", "assets:", strings.Join(e.Assets, ", "))
+	fmt.Fprintf(w, "  %-15s%s\n", "timeout:", timeout)
+	fmt.Fprintf(w, "  %-15s%s\n", "required vars:", strings.Join(e.RequiredVars, ", "))
+	if len(e.Options) == 0 {
+		fmt.Fprintf(w, "  %-15s\n", "options:")
+	} else if details {
+		opts, _ := json.MarshalIndent(e.Options, "  ", "  ")
+		fmt.Fprintf(w, "  %-15s%s\n", "options:", opts)
+	} else {
+		opts, _ := json.Marshal(e.Options)
+		fmt.Fprintf(w, "  %-15s%s\n", "options:", opts)
+	}
+	fmt.Fprintln(w)
+}
+
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
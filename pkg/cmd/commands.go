@@ -0,0 +1,137 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	agentlog "github.com/adevinta/vulcan-agent/log"
+	report "github.com/adevinta/vulcan-report"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/generator"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/reporting"
+)
+
+// Version is the version of vulcan-local, set at build time with
+// -ldflags "-X github.mpi-internal.com/spt-security/vulcan-local/pkg/cmd.Version=$VERSION".
+var Version = "dev"
+
+func init() {
+	if Version == "dev" {
+		if v := buildInfoVersion(); v != "" {
+			Version = v
+		}
+	}
+}
+
+// buildInfoVersion returns the module version when installed with go install,
+// or the vcs revision when built from a checkout.
+func buildInfoVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// newRunInfo returns the metadata of a scan starting now.
+func newRunInfo(cfg *config.Config) *reporting.RunInfo {
+	return &reporting.RunInfo{
+		ID:         uuid.New().String(),
+		Version:    Version,
+		StartTime:  time.Now(),
+		ConfigHash: cfg.Hash(),
+	}
+}
+
+// ValidateConfig checks the config is valid without running any check.
+func ValidateConfig(cfg *config.Config, log *logrus.Logger) (int, error) {
+	log.SetLevel(agentlog.ParseLogLevel(cfg.Conf.LogLevel))
+
+	if err := generator.ImportRepositories(cfg, log); err != nil {
+		return reporting.ErrorExitCode, fmt.Errorf("unable to import repositories %+v", err)
+	}
+
+	if err := printProblems(validateConfig(cfg), log); err != nil {
+		return reporting.ErrorExitCode, err
+	}
+	log.Infof("Config is valid")
+	return reporting.SuccessExitCode, nil
+}
+
+// RenderReport generates the report from a json or vulcan-local report written
+// in a previous scan applying the current reporting config.
+func RenderReport(cfg *config.Config, path string, log *logrus.Logger) (int, error) {
+	log.SetLevel(agentlog.ParseLogLevel(cfg.Conf.LogLevel))
+
+	if err := prepare(cfg); err != nil {
+		return reporting.ErrorExitCode, err
+	}
+
+	// Writing an output over the report being rendered would lose the scan.
+	for _, o := range cfg.Reporting.AllOutputs() {
+		if sameFile(o.File, path) {
+			return reporting.ErrorExitCode, fmt.Errorf("output file %s is the report being rendered", o.File)
+		}
+	}
+
+	saved, err := reporting.ReadSavedRun(path)
+	if err != nil {
+		return reporting.ErrorExitCode, err
+	}
+	// The checks of the saved run provide the original targets and asset types.
+	if saved.Checks != nil {
+		cfg.Checks = saved.Checks
+		// The exclusions of the repositories are loaded when the checks run.
+		if err := generator.LoadIgnoreFiles(cfg, log); err != nil {
+			return reporting.ErrorExitCode, err
+		}
+	}
+	m := map[string]*report.Report{}
+	for i := range saved.Reports {
+		r := &saved.Reports[i]
+		if r.CheckID == "" {
+			r.CheckID = fmt.Sprintf("%d", i)
+		}
+		m[r.CheckID] = r
+	}
+
+	run := newRunInfo(cfg)
+	run.EndTime = run.StartTime
+	if saved.Run != nil {
+		// Keep the metadata of the scan, with the config used to render it.
+		hash := run.ConfigHash
+		run = saved.Run
+		run.ConfigHash = hash
+	}
+	return reporting.Generate(cfg, m, run, log)
+}
+
+// sameFile returns true if both paths refer to the same file.
+func sameFile(a, b string) bool {
+	fa, errA := os.Stat(a)
+	fb, errB := os.Stat(b)
+	if errA == nil && errB == nil {
+		return os.SameFile(fa, fb)
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
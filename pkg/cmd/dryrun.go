@@ -0,0 +1,66 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/adevinta/vulcan-agent/jobrunner"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
+)
+
+// plannedJob describes a job that would be sent to the agent.
+type plannedJob struct {
+	Checktype      config.ChecktypeRef    `json:"checktype"`
+	Image          string                 `json:"image"`
+	Target         string                 `json:"target"`
+	NewTarget      string                 `json:"new_target"`
+	AssetType      string                 `json:"asset_type"`
+	Options        map[string]interface{} `json:"options"`
+	OptionsEncoded string                 `json:"-"`
+}
+
+// DryRunFormats are the formats of the checks printed in a dry run.
+var DryRunFormats = []string{"table", "json"}
+
+// printJobs prints the list of jobs generated from the config in the format
+// defined in DryRunFormat (table or json).
+func printJobs(cfg *config.Config, jobs []jobrunner.Job, w io.Writer) error {
+	planned := []plannedJob{}
+	for _, j := range jobs {
+		p := plannedJob{
+			Image:          j.Image,
+			Target:         j.Target,
+			NewTarget:      j.Target,
+			AssetType:      j.AssetType,
+			OptionsEncoded: j.Options,
+		}
+		if c := config.GetCheckById(cfg, j.CheckID); c != nil {
+			p.Checktype = c.Type
+			p.Target = c.Target
+		}
+		if err := json.Unmarshal([]byte(j.Options), &p.Options); err != nil {
+			return err
+		}
+		planned = append(planned, p)
+	}
+
+	switch cfg.Conf.DryRunFormat {
+	case "json":
+		return writeIndentedJSON(w, planned)
+	case "", "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CHECKTYPE\tIMAGE\tTARGET\tNEW TARGET\tASSET TYPE\tOPTIONS")
+		for _, p := range planned {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Checktype, p.Image, p.Target, p.NewTarget, p.AssetType, p.OptionsEncoded)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("invalid dry run format %s", cfg.Conf.DryRunFormat)
+	}
+}
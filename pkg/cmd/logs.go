@@ -0,0 +1,112 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	agentlog "github.com/adevinta/vulcan-agent/log"
+	"github.com/adevinta/vulcan-agent/stateupdater"
+	report "github.com/adevinta/vulcan-report"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
+)
+
+// logTailLines is the number of lines printed of the logs of a failed check.
+const logTailLines = 20
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// checkLog is the log of a check with the names identifying it.
+type checkLog struct {
+	checktype string
+	target    string
+	status    string
+	logs      []byte
+}
+
+// checkLogs returns the logs of the checks with report sorted by checktype
+// and target.
+func checkLogs(cfg *config.Config, reports map[string]*report.Report, logs map[string][]byte) []checkLog {
+	list := []checkLog{}
+	for id, r := range reports {
+		l := checkLog{
+			checktype: r.ChecktypeName,
+			target:    r.Target,
+			status:    r.Status,
+			logs:      logs[id],
+		}
+		if c := config.GetCheckById(cfg, id); c != nil {
+			l.target = c.Target
+		}
+		list = append(list, l)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].checktype != list[j].checktype {
+			return list[i].checktype < list[j].checktype
+		}
+		return list[i].target < list[j].target
+	})
+	return list
+}
+
+// logFileName returns a file name for the log of the check.
+func logFileName(checktype, target string) string {
+	name := unsafeFileChars.ReplaceAllString(fmt.Sprintf("%s_%s", checktype, target), "_")
+	return strings.Trim(name, "_.")
+}
+
+// writeLogs writes the log of each check in a file of the directory.
+func writeLogs(dir string, list []checkLog, log agentlog.Logger) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create logs dir %s %+v", dir, err)
+	}
+	used := map[string]bool{}
+	for _, l := range list {
+		if l.logs == nil {
+			continue
+		}
+		// The same checktype can run more than once on a target with different
+		// options, and the suffixed name can be the one of another check.
+		base := logFileName(l.checktype, l.target)
+		name := base
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[name] = true
+		path := filepath.Join(dir, name+".log")
+		if err := ioutil.WriteFile(path, l.logs, 0644); err != nil {
+			return fmt.Errorf("unable to write logs file %s %+v", path, err)
+		}
+		log.Debugf("Check logs written checktype=%s target=%s file=%s", l.checktype, l.target, path)
+	}
+	return nil
+}
+
+// printFailedLogs prints the last lines of the logs of the checks that didn't finish.
+func printFailedLogs(list []checkLog, log agentlog.Logger) {
+	for _, l := range list {
+		if l.status == stateupdater.StatusFinished || len(l.logs) == 0 {
+			continue
+		}
+		log.Errorf("Last lines of the logs of the check checktype=%s target=%s status=%s\n%s",
+			l.checktype, l.target, l.status, tail(l.logs, logTailLines))
+	}
+}
+
+// tail returns the last n lines of the logs.
+func tail(logs []byte, n int) string {
+	lines := bytes.Split(bytes.TrimRight(logs, "\n"), []byte("\n"))
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return string(bytes.Join(lines, []byte("\n")))
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	agentlog "github.com/adevinta/vulcan-agent/log"
+)
+
+func TestLogFileName(t *testing.T) {
+	tests := []struct {
+		checktype string
+		target    string
+		expected  string
+	}{
+		{"vulcan-zap", "http://localhost:1234/", "vulcan-zap_http_localhost_1234"},
+		{"vulcan-seekret", ".", "vulcan-seekret"},
+		{"vulcan-trivy", "registry.example.com/app:1.0", "vulcan-trivy_registry.example.com_app_1.0"},
+		{"vulcan-nessus", "../../etc/passwd", "vulcan-nessus_.._.._etc_passwd"},
+	}
+	for _, tt := range tests {
+		if got := logFileName(tt.checktype, tt.target); got != tt.expected {
+			t.Errorf("logFileName(%q, %q)==%q expected %q", tt.checktype, tt.target, got, tt.expected)
+		}
+	}
+}
+
+func TestTail(t *testing.T) {
+	tests := []struct {
+		logs     string
+		n        int
+		expected string
+	}{
+		{"", 2, ""},
+		{"a\nb\n", 2, "a\nb"},
+		{"a\nb\nc\nd\n", 2, "c\nd"},
+		{"a\nb\nc", 2, "b\nc"},
+		{"a\n\n\n", 2, "a"},
+	}
+	for _, tt := range tests {
+		if got := tail([]byte(tt.logs), tt.n); got != tt.expected {
+			t.Errorf("tail(%q, %d)==%q expected %q", tt.logs, tt.n, got, tt.expected)
+		}
+	}
+}
+
+func TestWriteLogs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	list := []checkLog{
+		{checktype: "vulcan-zap", target: "http://localhost", logs: []byte("first")},
+		{checktype: "vulcan-zap", target: "http://localhost", logs: []byte("second")},
+		{checktype: "vulcan-zap", target: "http://localhost_2", logs: []byte("other target")},
+		{checktype: "vulcan-seekret", target: ".", logs: nil}, // Without logs
+	}
+	if err := writeLogs(dir, list, &agentlog.NullLog{}); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, f := range files {
+		content, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[f.Name()] = string(content)
+	}
+	expected := map[string]string{
+		"vulcan-zap_http_localhost.log":     "first",
+		"vulcan-zap_http_localhost_2.log":   "second",
+		"vulcan-zap_http_localhost_2_2.log": "other target",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		names := []string{}
+		for name := range got {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		t.Fatalf("unexpected log files %s", strings.Join(names, ", "))
+	}
+}
@@ -6,6 +6,7 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"net"
 	"os"
@@ -14,6 +15,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/phayes/freeport"
 	"github.com/sirupsen/logrus"
@@ -21,7 +23,10 @@ import (
 	"github.com/adevinta/vulcan-agent/agent"
 	"github.com/adevinta/vulcan-agent/backend/docker"
 	agentconfig "github.com/adevinta/vulcan-agent/config"
+	"github.com/adevinta/vulcan-agent/jobrunner"
 	agentlog "github.com/adevinta/vulcan-agent/log"
+	"github.com/adevinta/vulcan-agent/stateupdater"
+	report "github.com/adevinta/vulcan-report"
 	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
 	"github.mpi-internal.com/spt-security/vulcan-local/pkg/generator"
 	"github.mpi-internal.com/spt-security/vulcan-local/pkg/gitservice"
@@ -34,45 +39,51 @@ const defaultDockerHost = "host.docker.internal"
 
 func Run(cfg *config.Config, log *logrus.Logger) (int, error) {
 	var err error
+	run := newRunInfo(cfg)
 
 	log.SetLevel(agentlog.ParseLogLevel(cfg.Conf.LogLevel))
 
-	if err = checkDependencies(cfg, log); err != nil {
-		return reporting.ErrorExitCode, fmt.Errorf("unmet dependencies %+v", err)
-	}
-
-	if cfg.Conf.Include != "" {
-		if cfg.Conf.IncludeR, err = regexp.Compile(cfg.Conf.Include); err != nil {
-			return reporting.ErrorExitCode, fmt.Errorf("invalid include regexp %+v", err)
+	// Dry run doesn't require docker
+	if !cfg.Conf.DryRun {
+		if err = checkDependencies(cfg, log); err != nil {
+			return reporting.ErrorExitCode, fmt.Errorf("unmet dependencies %+v", err)
 		}
 	}
-	if cfg.Conf.Exclude != "" {
-		if cfg.Conf.ExcludeR, err = regexp.Compile(cfg.Conf.Exclude); err != nil {
-			return reporting.ErrorExitCode, fmt.Errorf("invalid exclude regexp %+v", err)
-		}
+
+	err = generator.ImportRepositories(cfg, log)
+	if err != nil {
+		return reporting.ErrorExitCode, fmt.Errorf("unable to generate checks %+v", err)
 	}
 
-	if _, err := reporting.FindSeverity(cfg.Reporting.Threshold); err != nil {
+	if err = printProblems(validateConfig(cfg), log); err != nil {
 		return reporting.ErrorExitCode, err
 	}
 
-	err = generator.ImportRepositories(cfg, log)
-	if err != nil {
-		return reporting.ErrorExitCode, fmt.Errorf("unable to generate checks %+v", err)
+	if err = prepare(cfg); err != nil {
+		return reporting.ErrorExitCode, err
 	}
 
 	if err = generator.GenerateChecksFromTargets(cfg, log); err != nil {
 		return reporting.ErrorExitCode, err
 	}
 
-	agentIp := GetAgentIP(cfg.Conf.IfName, log)
-	if agentIp == "" {
-		return reporting.ErrorExitCode, fmt.Errorf("unable to get the agent ip %s", cfg.Conf.IfName)
-	}
+	var agentIp, hostIp string
+	if cfg.Conf.DryRun {
+		// Use placeholders as the addresses depend on docker.
+		if agentIp, err = GetInterfaceAddr(cfg.Conf.IfName); err != nil {
+			agentIp = defaultDockerHost
+		}
+		hostIp = defaultDockerHost
+	} else {
+		agentIp = GetAgentIP(cfg.Conf.IfName, log)
+		if agentIp == "" {
+			return reporting.ErrorExitCode, fmt.Errorf("unable to get the agent ip %s", cfg.Conf.IfName)
+		}
 
-	hostIp := GetHostIP(log)
-	if hostIp == "" {
-		return reporting.ErrorExitCode, fmt.Errorf("unable to infer host ip")
+		hostIp = GetHostIP(log)
+		if hostIp == "" {
+			return reporting.ErrorExitCode, fmt.Errorf("unable to infer host ip")
+		}
 	}
 
 	gs := gitservice.New(log)
@@ -83,6 +94,13 @@ func Run(cfg *config.Config, log *logrus.Logger) (int, error) {
 		return reporting.ErrorExitCode, fmt.Errorf("unable to generate checks %+v", err)
 	}
 
+	if cfg.Conf.DryRun {
+		if err = printJobs(cfg, jobs, os.Stdout); err != nil {
+			return reporting.ErrorExitCode, fmt.Errorf("unable to print checks %+v", err)
+		}
+		return reporting.SuccessExitCode, nil
+	}
+
 	if len(jobs) == 0 {
 		log.Infof("Empty list of checks")
 		return reporting.SuccessExitCode, nil
@@ -166,12 +184,44 @@ func Run(cfg *config.Config, log *logrus.Logger) (int, error) {
 		logAgent.SetFormatter(log.Formatter)
 		logAgent.SetLevel(logrus.ErrorLevel)
 	}
+	// Read the Status queue while the agent runs to show the progress and to
+	// detect the checks without report.
+	tracker := sqsservice.NewStatusTracker(log)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	readDone, err := sqs.ReadStatus(ctx, tracker, logAgent)
+	if err != nil {
+		return reporting.ErrorExitCode, fmt.Errorf("unable to read the status queue %+v", err)
+	}
+	// The view is updated in place only if the logs are not going to interleave.
+	view := newProgressView(cfg, jobs, tracker, isTerminal(os.Stdout) && log.Level != logrus.DebugLevel, log)
+	viewDone := view.Run(ctx)
+
 	exit := agent.Run(agentConfig, backend, logAgent.WithField("comp", "agent"))
+
+	// Give some time to read the last status messages.
+	view.Wait(5 * time.Second)
+	cancel()
+	<-viewDone
+	if err = <-readDone; err != nil && err != context.Canceled {
+		log.Errorf("Error reading the status queue %+v", err)
+	}
 	if exit != 0 {
 		return reporting.ErrorExitCode, fmt.Errorf("error running the agent exit=%d", exit)
 	}
 
-	reportCode, err := reporting.Generate(cfg, results, log)
+	addMissingReports(cfg, jobs, results.Checks, tracker.Statuses(), log)
+
+	logs := checkLogs(cfg, results.Checks, results.Logs)
+	if cfg.Reporting.LogsDir != "" {
+		if err = writeLogs(cfg.Reporting.LogsDir, logs, log); err != nil {
+			log.Errorf("Error writing the logs of the checks %+v", err)
+		}
+	}
+	printFailedLogs(logs, log)
+
+	run.EndTime = time.Now()
+	reportCode, err := reporting.Generate(cfg, results.Checks, run, log)
 	if err != nil {
 		return reporting.ErrorExitCode, fmt.Errorf("error generating report %+v", err)
 	}
@@ -179,6 +229,69 @@ func Run(cfg *config.Config, log *logrus.Logger) (int, error) {
 	return reportCode, nil
 }
 
+// addMissingReports adds a report for each job that didn't upload one, with
+// the last status read from the status queue or ABORTED if it didn't end.
+func addMissingReports(cfg *config.Config, jobs []jobrunner.Job, reports map[string]*report.Report, statuses map[string]string, log agentlog.Logger) {
+	for _, j := range jobs {
+		if _, ok := reports[j.CheckID]; ok {
+			continue
+		}
+		status := statuses[j.CheckID]
+		if _, ok := stateupdater.TerminalStatuses[status]; !ok {
+			status = stateupdater.StatusAborted
+		}
+		r := &report.Report{}
+		r.CheckID = j.CheckID
+		r.Target = j.Target
+		r.Status = status
+		if c := config.GetCheckById(cfg, j.CheckID); c != nil {
+			r.ChecktypeName = string(c.Type)
+			if ct, err := generator.GetCheckType(cfg, c.Type); err == nil {
+				r.ChecktypeName = ct.Name
+			}
+		}
+		log.Errorf("Check without report checktype=%s target=%s status=%s", r.ChecktypeName, r.Target, r.Status)
+		reports[j.CheckID] = r
+	}
+}
+
+// compileFilters compiles the include and exclude checktype regexes.
+func compileFilters(cfg *config.Config) error {
+	var err error
+	if cfg.Conf.Include != "" {
+		if cfg.Conf.IncludeR, err = regexp.Compile(cfg.Conf.Include); err != nil {
+			return fmt.Errorf("invalid include regexp %+v", err)
+		}
+	}
+	if cfg.Conf.Exclude != "" {
+		if cfg.Conf.ExcludeR, err = regexp.Compile(cfg.Conf.Exclude); err != nil {
+			return fmt.Errorf("invalid exclude regexp %+v", err)
+		}
+	}
+	return nil
+}
+
+// prepare compiles the checktype filters and checks the reporting settings.
+func prepare(cfg *config.Config) error {
+	if err := compileFilters(cfg); err != nil {
+		return err
+	}
+
+	if _, err := reporting.FindSeverity(cfg.Reporting.Threshold); err != nil {
+		return err
+	}
+
+	if !reporting.IsFormat(cfg.Reporting.Format) {
+		return fmt.Errorf("report format unknown %s, allowed values %v", cfg.Reporting.Format, reporting.FormatNames())
+	}
+	for _, o := range cfg.Reporting.AllOutputs() {
+		if !reporting.IsFormat(o.Format) {
+			return fmt.Errorf("report format unknown %s, allowed values %v", o.Format, reporting.FormatNames())
+		}
+	}
+	return nil
+}
+
 // checkDependencies checks that all the dependencies are present and run
 // normally.
 func checkDependencies(cfg *config.Config, log agentlog.Logger) error {
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/adevinta/vulcan-agent/jobrunner"
+	agentlog "github.com/adevinta/vulcan-agent/log"
+	report "github.com/adevinta/vulcan-report"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
+)
+
+func TestAddMissingReports(t *testing.T) {
+	cfg := &config.Config{
+		Checks: []config.Check{
+			{Id: "1", Type: "vulcan-zap", Target: "http://localhost"},
+			{Id: "2", Type: "custom/vulcan-seekret", Target: "."},
+			{Id: "3", Type: "vulcan-unknown", Target: "."},
+		},
+		CheckTypes: map[config.ChecktypeRef]config.Checktype{
+			"default/vulcan-zap":    {Name: "vulcan-zap"},
+			"custom/vulcan-seekret": {Name: "vulcan-seekret"},
+		},
+	}
+	tests := []struct {
+		name      string
+		checkID   string
+		reported  bool
+		status    string
+		checktype string
+		expected  string
+	}{
+		{"reported", "1", true, "RUNNING", "vulcan-zap", "FINISHED"},
+		{"without status", "1", false, "", "vulcan-zap", "ABORTED"},
+		{"running", "1", false, "RUNNING", "vulcan-zap", "ABORTED"},
+		{"terminal status", "2", false, "TIMEOUT", "vulcan-seekret", "TIMEOUT"},
+		{"failed", "2", false, "FAILED", "vulcan-seekret", "FAILED"},
+		{"unknown checktype", "3", false, "FAILED", "vulcan-unknown", "FAILED"},
+		{"unknown check", "4", false, "FAILED", "", "FAILED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reports := map[string]*report.Report{}
+			if tt.reported {
+				r := &report.Report{}
+				r.CheckID, r.ChecktypeName, r.Status = tt.checkID, tt.checktype, "FINISHED"
+				reports[tt.checkID] = r
+			}
+			jobs := []jobrunner.Job{{CheckID: tt.checkID, Target: "http://172.17.0.1/"}}
+			addMissingReports(cfg, jobs, reports, map[string]string{tt.checkID: tt.status}, &agentlog.NullLog{})
+			r, ok := reports[tt.checkID]
+			if !ok {
+				t.Fatalf("missing report of check %s", tt.checkID)
+			}
+			if r.Status != tt.expected || r.ChecktypeName != tt.checktype || r.CheckID != tt.checkID {
+				t.Errorf("unexpected report %+v", r.CheckData)
+			}
+			if !tt.reported && r.Target != "http://172.17.0.1/" {
+				t.Errorf("got target %s, want the job target", r.Target)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/adevinta/vulcan-agent/jobrunner"
+	agentlog "github.com/adevinta/vulcan-agent/log"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/generator"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/sqsservice"
+)
+
+const progressInterval = time.Second
+
+// progressCheck identifies a check in the progress view.
+type progressCheck struct {
+	id        string
+	checktype string
+	target    string
+}
+
+// progressView renders the state of the checks read from the Status queue.
+// In a terminal the view is updated in place, otherwise a log line is printed
+// each time a check changes.
+type progressView struct {
+	checks  []progressCheck
+	tracker *sqsservice.StatusTracker
+	out     io.Writer
+	tty     bool
+	log     agentlog.Logger
+	lines   int
+	last    map[string]sqsservice.CheckProgress
+	started time.Time
+}
+
+func newProgressView(cfg *config.Config, jobs []jobrunner.Job, tracker *sqsservice.StatusTracker, tty bool, log agentlog.Logger) *progressView {
+	checks := []progressCheck{}
+	for _, j := range jobs {
+		p := progressCheck{id: j.CheckID, target: j.Target}
+		if c := config.GetCheckById(cfg, j.CheckID); c != nil {
+			p.checktype, p.target = string(c.Type), c.Target
+			if ct, err := generator.GetCheckType(cfg, c.Type); err == nil {
+				p.checktype = ct.Name
+			}
+		}
+		checks = append(checks, p)
+	}
+	return &progressView{
+		checks:  checks,
+		tracker: tracker,
+		out:     os.Stdout,
+		tty:     tty,
+		log:     log,
+		last:    map[string]sqsservice.CheckProgress{},
+		started: time.Now(),
+	}
+}
+
+// isTerminal returns true if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Run renders the progress until the context is canceled. The returned channel
+// is closed after the last render.
+func (v *progressView) Run(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				v.render()
+				return
+			case <-ticker.C:
+				v.render()
+			}
+		}
+	}()
+	return done
+}
+
+// Wait waits until all the checks reached a terminal status or the timeout
+// expires.
+func (v *progressView) Wait(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		states := v.tracker.Checks()
+		pending := false
+		for _, c := range v.checks {
+			if !states[c.id].Terminal() {
+				pending = true
+				break
+			}
+		}
+		if !pending {
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func (v *progressView) render() {
+	states := v.tracker.Checks()
+	if !v.tty {
+		for _, c := range v.checks {
+			s, ok := states[c.id]
+			if !ok || s == v.last[c.id] {
+				continue
+			}
+			v.last[c.id] = s
+			v.log.Infof("Check status checktype=%s target=%s status=%s progress=%.0f%% elapsed=%s",
+				c.checktype, c.target, s.Status, s.Progress*100, elapsed(s, time.Now()))
+		}
+		return
+	}
+
+	now := time.Now()
+	buf := new(bytes.Buffer)
+	if v.lines > 0 {
+		// Move the cursor to the start of the previous view and clear it.
+		fmt.Fprintf(buf, "\x1b[%dA\x1b[J", v.lines)
+	}
+	finished := 0
+	for _, c := range v.checks {
+		if states[c.id].Terminal() {
+			finished++
+		}
+	}
+	fmt.Fprintf(buf, "Checks %d/%d finished %s\n", finished, len(v.checks), now.Sub(v.started).Truncate(time.Second))
+	for _, c := range v.checks {
+		s := states[c.id]
+		status := s.Status
+		if status == "" {
+			status = "QUEUED"
+		}
+		fmt.Fprintf(buf, "  %-12s %4.0f%% %8s  %s %s\n", status, s.Progress*100, elapsed(s, now), c.checktype, c.target)
+	}
+	v.lines = len(v.checks) + 1
+	v.out.Write(buf.Bytes())
+}
+
+// elapsed returns the running time of the check.
+func elapsed(s sqsservice.CheckProgress, now time.Time) time.Duration {
+	if s.Start.IsZero() {
+		return 0
+	}
+	if !s.End.IsZero() {
+		now = s.End
+	}
+	return now.Sub(s.Start).Truncate(time.Second)
+}
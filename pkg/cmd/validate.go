@@ -0,0 +1,164 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/adevinta/vulcan-agent/stateupdater"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/generator"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/reporting"
+)
+
+// validateConfig returns the problems of the config. The checktype
+// repositories must be already imported.
+func validateConfig(cfg *config.Config) []config.Problem {
+	problems := cfg.Validate()
+
+	if _, err := reporting.FindSeverity(cfg.Reporting.Threshold); err != nil {
+		problems = append(problems, config.Problem{
+			Location: cfg.Locate("reporting", "threshold"),
+			Message:  fmt.Sprintf("invalid threshold %q, allowed values %v", cfg.Reporting.Threshold, reporting.SeverityNames()),
+		})
+	}
+
+	if !reporting.IsFormat(cfg.Reporting.Format) {
+		problems = append(problems, config.Problem{
+			Location: cfg.Locate("reporting", "format"),
+			Message:  fmt.Sprintf("invalid format %q, allowed values %v", cfg.Reporting.Format, reporting.FormatNames()),
+		})
+	}
+
+	for i, o := range cfg.Reporting.Outputs {
+		if o.Format != "" && !reporting.IsFormat(o.Format) {
+			problems = append(problems, config.Problem{
+				Location: cfg.Locate("reporting", "outputs", strconv.Itoa(i), "format"),
+				Message:  fmt.Sprintf("invalid output format %q, allowed values %v", o.Format, reporting.FormatNames()),
+			})
+		}
+		if o.File == "" {
+			problems = append(problems, config.Problem{
+				Location: cfg.Locate("reporting", "outputs", strconv.Itoa(i)),
+				Message:  "output without file",
+			})
+		}
+	}
+
+	columns := map[string]bool{}
+	for _, name := range reporting.CSVColumnNames() {
+		columns[name] = true
+	}
+	for i, name := range cfg.Reporting.CSVColumns {
+		if !columns[name] {
+			msg := fmt.Sprintf("invalid csv column %q, allowed values %v", name, reporting.CSVColumnNames())
+			if best := config.Suggest(name, reporting.CSVColumnNames()); best != "" {
+				msg = fmt.Sprintf("%s, did you mean %q?", msg, best)
+			}
+			problems = append(problems, config.Problem{
+				Location: cfg.Locate("reporting", "csvColumns", strconv.Itoa(i)),
+				Message:  msg,
+			})
+		}
+	}
+
+	for i := range cfg.Reporting.Exclusions {
+		e := &cfg.Reporting.Exclusions[i]
+		if e.MaxSeverity == "" {
+			continue
+		}
+		if _, err := reporting.FindSeverity(e.MaxSeverity); err != nil {
+			problems = append(problems, config.Problem{
+				Location: e.Locate("maxSeverity"),
+				Message:  fmt.Sprintf("invalid exclusion maxSeverity %q, allowed values %v", e.MaxSeverity, reporting.SeverityNames()),
+			})
+		}
+	}
+
+	for i, r := range cfg.Reporting.Policy {
+		rule := strconv.Itoa(i)
+		if r.Action != "" && r.Action != config.PolicyFail && r.Action != config.PolicyIgnore {
+			problems = append(problems, config.Problem{
+				Location: cfg.Locate("reporting", "policy", rule, "action"),
+				Message:  fmt.Sprintf("invalid policy action %q, allowed values [%s %s]", r.Action, config.PolicyFail, config.PolicyIgnore),
+			})
+		}
+		for _, f := range []struct {
+			name  string
+			value string
+		}{{"minSeverity", r.MinSeverity}, {"maxSeverity", r.MaxSeverity}} {
+			if f.value == "" {
+				continue
+			}
+			if _, err := reporting.FindSeverity(f.value); err != nil {
+				problems = append(problems, config.Problem{
+					Location: cfg.Locate("reporting", "policy", rule, f.name),
+					Message:  fmt.Sprintf("invalid policy %s %q, allowed values %v", f.name, f.value, reporting.SeverityNames()),
+				})
+			}
+		}
+		if r.MaxCount < 0 {
+			problems = append(problems, config.Problem{
+				Location: cfg.Locate("reporting", "policy", rule, "maxCount"),
+				Message:  fmt.Sprintf("invalid policy maxCount %d, it can't be negative", r.MaxCount),
+			})
+		}
+		if len(r.CheckStatus) > 0 && (r.MinSeverity != "" || r.MaxSeverity != "") {
+			problems = append(problems, config.Problem{
+				Location: cfg.Locate("reporting", "policy", rule, "checkStatus"),
+				Message:  "policy rules with checkStatus can't define severities",
+			})
+		}
+		for _, s := range r.CheckStatus {
+			if _, ok := stateupdater.TerminalStatuses[s]; !ok && s != stateupdater.StatusAborted {
+				problems = append(problems, config.Problem{
+					Location: cfg.Locate("reporting", "policy", rule, "checkStatus"),
+					Message:  fmt.Sprintf("invalid policy checkStatus %q, it must be a final status", s),
+				})
+			}
+		}
+	}
+
+	for i := range cfg.Checks {
+		c := &cfg.Checks[i]
+		ct, err := generator.GetCheckType(cfg, c.Type)
+		if err != nil {
+			problems = append(problems, config.Problem{
+				Location: c.Locate("type"),
+				Message:  fmt.Sprintf("unknown checktype %q for target %s", c.Type, c.Target),
+			})
+			continue
+		}
+		for _, e := range generator.ValidateOptions(ct.Options, c.Options) {
+			problems = append(problems, config.Problem{
+				Location: c.Locate("options", e.Option),
+				Warning:  !c.StrictOptions,
+				Message:  fmt.Sprintf("checktype %s %s", c.Type, e),
+			})
+		}
+	}
+	return problems
+}
+
+// printProblems logs the problems and returns an error if any of them is not
+// a warning.
+func printProblems(problems []config.Problem, log *logrus.Logger) error {
+	errors := 0
+	for _, p := range problems {
+		if p.Warning {
+			log.Warnf("%s", p)
+			continue
+		}
+		log.Errorf("%s", p)
+		errors++
+	}
+	if errors > 0 {
+		return fmt.Errorf("invalid config, %d errors found", errors)
+	}
+	return nil
+}
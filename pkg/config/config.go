@@ -5,11 +5,16 @@ Copyright 2021 Adevinta
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 	"time"
@@ -28,22 +33,27 @@ type Check struct {
 	Options   map[string]interface{} `yaml:"options,omitempty"`
 	Timeout   int                    `yaml:"timeout,omitempty"`
 	AssetType string                 `yaml:"assetType,omitempty"`
-	NewTarget string
-	Id        string
+	// StrictOptions makes the invalid options an error instead of a warning.
+	StrictOptions bool   `yaml:"strictOptions,omitempty"`
+	NewTarget     string `yaml:"-"`
+	Id            string `yaml:"-"`
+	node          nodeRef
 }
 
 type Target struct {
-	Target    string
-	AssetType string
-	Options   map[string]interface{}
+	Target    string                 `yaml:"target"`
+	AssetType string                 `yaml:"assetType,omitempty"`
+	Options   map[string]interface{} `yaml:"options,omitempty"`
 }
 
 type Config struct {
+	Include    []string                   `yaml:"include"`
 	Conf       Conf                       `yaml:"conf"`
 	Reporting  Reporting                  `yaml:"reporting,omitempty"`
 	Checks     []Check                    `yaml:"checks"`
 	Targets    []Target                   `yaml:"targets"`
 	CheckTypes map[ChecktypeRef]Checktype `yaml:"checkTypes"`
+	sources    []nodeRef
 }
 
 type Conf struct {
@@ -58,22 +68,150 @@ type Conf struct {
 	IfName       string            `yaml:"ifName"`
 	Exclude      string            `yaml:"exclude"`
 	Include      string            `yaml:"include"`
-	IncludeR     *regexp.Regexp
-	ExcludeR     *regexp.Regexp
+	IncludeR     *regexp.Regexp    `yaml:"-"`
+	ExcludeR     *regexp.Regexp    `yaml:"-"`
+	DryRun       bool              `yaml:"-"`
+	DryRunFormat string            `yaml:"-"`
 }
 
 type Exclusion struct {
-	Target           string `yaml:"target"`
-	Summary          string `yaml:"summary"`
-	AffectedResource string `yaml:"affectedResource"`
-	Fingerprint      string `yaml:"fingerprint"`
+	Target           Matcher `yaml:"target"`
+	Summary          Matcher `yaml:"summary"`
+	AffectedResource Matcher `yaml:"affectedResource"`
+	Fingerprint      Matcher `yaml:"fingerprint"`
+	Checktype        Matcher `yaml:"checktype,omitempty"`
+	AssetType        Matcher `yaml:"assetType,omitempty"`
+	// MaxSeverity limits the exclusion to the vulnerabilities with this severity or lower.
+	MaxSeverity string `yaml:"maxSeverity,omitempty"`
+	Reason      string `yaml:"reason,omitempty"`
+	Owner       string `yaml:"owner,omitempty"`
+	Expires     Date   `yaml:"expires,omitempty"`
+	Ticket      string `yaml:"ticket,omitempty"`
+	node        nodeRef
+}
+
+// Expired returns true if the exclusion has an expiry date and it is not
+// after t, i.e. the exclusion no longer applies from the expiry date.
+func (e *Exclusion) Expired(t time.Time) bool {
+	return !e.Expires.IsZero() && !t.Before(e.Expires.Time)
+}
+
+// Date is a date in YYYY-MM-DD format.
+type Date struct {
+	time.Time
+	err error // Reported with its location when validating the config
+}
+
+const dateLayout = "2006-01-02"
+
+// parseDate returns the date of the yaml node.
+func parseDate(n *yaml.Node) (time.Time, error) {
+	t, err := time.Parse(dateLayout, n.Value)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, n.Value); err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", n.Value)
+		}
+	}
+	return t, nil
+}
+
+// UnmarshalYAML doesn't fail on invalid dates so the rest of the config is
+// validated, their errors are reported by checkNode.
+func (d *Date) UnmarshalYAML(n *yaml.Node) error {
+	d.Time, d.err = parseDate(n)
+	return nil
+}
+
+func (d *Date) checkNode(n *yaml.Node) error {
+	_, err := parseDate(n)
+	return err
+}
+
+func (d Date) String() string {
+	return d.Format(dateLayout)
 }
 
 type Reporting struct {
-	Threshold  string      `yaml:"threshold"`
-	Format     string      `yaml:"format"`
-	OutputFile string      `yaml:"outputFile"`
-	Exclusions []Exclusion `yaml:"exclusions"`
+	Threshold  string       `yaml:"threshold"`
+	Format     string       `yaml:"format"`
+	OutputFile string       `yaml:"outputFile"`
+	Baseline   string       `yaml:"baseline"`
+	Exclusions []Exclusion  `yaml:"exclusions"`
+	Policy     []PolicyRule `yaml:"policy"`
+	// OnCheckError is the behavior when checks don't complete: fail, warn (default) or ignore.
+	OnCheckError string `yaml:"onCheckError"`
+	// LogsDir is the directory where the logs of each check are written.
+	LogsDir string `yaml:"logsDir"`
+	// MarkdownMaxSize is the size limit in bytes of the markdown report.
+	MarkdownMaxSize int `yaml:"markdownMaxSize"`
+	// CSVColumns are the columns of the csv report, all of them by default.
+	CSVColumns []string `yaml:"csvColumns"`
+	// Outputs are the report files written in addition to outputFile.
+	Outputs []Output `yaml:"outputs"`
+	// Unfiltered writes the excluded and below threshold vulnerabilities in
+	// all the outputs.
+	Unfiltered bool `yaml:"unfiltered"`
+}
+
+// Output is a report file written in a format.
+type Output struct {
+	Format     string `yaml:"format"` // reporting.format if empty
+	File       string `yaml:"file"`
+	Unfiltered bool   `yaml:"unfiltered"`
+}
+
+// ParseOutput parses a format=file output, the format is optional.
+func ParseOutput(s string) Output {
+	if i := strings.Index(s, "="); i > 0 && !strings.ContainsAny(s[:i], `./\`) {
+		return Output{Format: s[:i], File: s[i+1:]}
+	}
+	return Output{File: s}
+}
+
+// AllOutputs returns the outputFile followed by the outputs, with the format
+// of the reporting when not set and unfiltered if the reporting is.
+func (r *Reporting) AllOutputs() []Output {
+	outputs := []Output{}
+	if r.OutputFile != "" {
+		outputs = append(outputs, Output{Format: r.Format, File: r.OutputFile, Unfiltered: r.Unfiltered})
+	}
+	for _, o := range r.Outputs {
+		if o.Format == "" {
+			o.Format = r.Format
+		}
+		o.Unfiltered = o.Unfiltered || r.Unfiltered
+		outputs = append(outputs, o)
+	}
+	return outputs
+}
+
+// Behaviors when checks don't complete.
+const (
+	OnCheckErrorFail   = "fail"
+	OnCheckErrorWarn   = "warn"
+	OnCheckErrorIgnore = "ignore"
+)
+
+// Policy rule actions.
+const (
+	PolicyFail   = "fail"
+	PolicyIgnore = "ignore"
+)
+
+// PolicyRule is a rule deciding the exit code from the vulnerabilities, or
+// from the status of the checks when CheckStatus is set.
+type PolicyRule struct {
+	Name string `yaml:"name,omitempty"`
+	// Action is fail (default) or ignore.
+	Action      string  `yaml:"action,omitempty"`
+	MinSeverity string  `yaml:"minSeverity,omitempty"`
+	MaxSeverity string  `yaml:"maxSeverity,omitempty"`
+	Checktype   Matcher `yaml:"checktype,omitempty"`
+	Target      Matcher `yaml:"target,omitempty"`
+	// MaxCount is the number of matches allowed before failing.
+	MaxCount    int      `yaml:"maxCount,omitempty"`
+	CheckStatus []string `yaml:"checkStatus,omitempty"`
+	ExitCode    int      `yaml:"exitCode,omitempty"`
 }
 
 // Definition borrowed from vulcan-checks-bsys.
@@ -134,27 +272,88 @@ func GetManifestFromFile(path string) ([]Checktype, error) {
 	return ct.CheckTypes, nil
 }
 
+// ReadConfig reads the config file merging it over the current config.
+// The files in the include list are read before the file including them,
+// the lists of checks, targets and exclusions are appended, the maps are
+// merged and the scalars are overridden by the last file defining them.
 func ReadConfig(path string, cfg *Config, l log.Logger) error {
-	var bytes []byte
-	var err error
-	if path == "-" {
-		bytes, err = ioutil.ReadAll(os.Stdin)
-	} else {
-		bytes, err = ioutil.ReadFile(path)
+	return readConfig(path, cfg, []string{}, l)
+}
+
+func readConfig(path string, cfg *Config, stack []string, l log.Logger) error {
+	if path != "-" && !isURL(path) {
+		path = filepath.Clean(path)
+	}
+	for _, p := range stack {
+		if p == path {
+			return fmt.Errorf("include cycle %s -> %s", strings.Join(stack, " -> "), path)
+		}
 	}
+	// The included files could be modified in transit.
+	if len(stack) > 0 && strings.HasPrefix(path, "http://") {
+		return fmt.Errorf("insecure include %s, use https", path)
+	}
+	stack = append(stack, path)
+
+	bytes, err := readConfigFile(path)
 	if err != nil {
 		return err
 	}
 
-	c, err := envsubst.EvalEnv(string(bytes))
-	if err != nil {
+	// The remote files could leak the environment, i.e. CI secrets, in the
+	// urls of their includes.
+	if !isURL(path) {
+		c, err := envsubst.EvalEnv(string(bytes))
+		if err != nil {
+			return err
+		}
+		bytes = []byte(c)
+	}
+	node := &yaml.Node{}
+	if err = yaml.Unmarshal(bytes, node); err != nil {
 		return err
 	}
-	bytes = []byte(c)
-	err = yaml.Unmarshal(bytes, cfg)
-	if err != nil {
+
+	inc := struct {
+		Include []string `yaml:"include"`
+	}{}
+	if err = node.Decode(&inc); err != nil {
+		return err
+	}
+	for _, i := range inc.Include {
+		included := resolveInclude(path, i)
+		l.Debugf("Including config file=%s from=%s", included, path)
+		if err = readConfig(included, cfg, stack, l); err != nil {
+			return fmt.Errorf("unable to include %s %+v", included, err)
+		}
+	}
+
+	// The lists are replaced when decoding, keep the previous elements to append them.
+	checks, targets, exclusions := cfg.Checks, cfg.Targets, cfg.Reporting.Exclusions
+	cfg.Checks, cfg.Targets, cfg.Reporting.Exclusions = nil, nil, nil
+	if err = node.Decode(cfg); err != nil {
 		return err
 	}
+	cfg.Checks = append(checks, cfg.Checks...)
+	cfg.Targets = append(targets, cfg.Targets...)
+	cfg.Reporting.Exclusions = append(exclusions, cfg.Reporting.Exclusions...)
+
+	// Keep the parsed document to locate the problems found validating the config.
+	if path == "-" {
+		path = "stdin"
+	}
+	src := nodeRef{file: path, node: node, content: bytes}
+	cfg.sources = append(cfg.sources, src)
+	if n := src.lookup("checks"); n != nil && n.Kind == yaml.SequenceNode {
+		for i, cn := range n.Content {
+			cfg.Checks[len(checks)+i].node = nodeRef{file: path, node: cn}
+		}
+	}
+	if n := src.lookup("reporting", "exclusions"); n != nil && n.Kind == yaml.SequenceNode {
+		for i, en := range n.Content {
+			cfg.Reporting.Exclusions[len(exclusions)+i].node = nodeRef{file: path, node: en}
+		}
+	}
 
 	if (*cfg).CheckTypes == nil {
 		(*cfg).CheckTypes = make(map[ChecktypeRef]Checktype)
@@ -162,6 +361,94 @@ func ReadConfig(path string, cfg *Config, l log.Logger) error {
 	return nil
 }
 
+// IgnoreFile is the file at the root of the git repositories with the
+// exclusions for the vulnerabilities of the repository.
+const IgnoreFile = ".vulcanignore"
+
+// ReadIgnoreFile reads the list of exclusions in the ignore file of the
+// repository, scoped to the target. Returns nil if the file doesn't exist.
+func ReadIgnoreFile(dir, target string) ([]Exclusion, error) {
+	path := filepath.Join(dir, IgnoreFile)
+	bytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	node := &yaml.Node{}
+	if err = yaml.Unmarshal(bytes, node); err != nil {
+		return nil, fmt.Errorf("unable to parse %s %+v", path, err)
+	}
+	exclusions := []Exclusion{}
+	if err = node.Decode(&exclusions); err != nil {
+		return nil, fmt.Errorf("unable to parse %s %+v", path, err)
+	}
+	if len(node.Content) > 0 && node.Content[0].Kind == yaml.SequenceNode {
+		src := nodeRef{file: path, node: node}
+		if problems := checkFields(src, node.Content[0], reflect.TypeOf(exclusions), ""); len(problems) > 0 {
+			return nil, fmt.Errorf("invalid exclusions %s", problems[0])
+		}
+		for i, n := range node.Content[0].Content {
+			exclusions[i].node = nodeRef{file: path, node: n}
+		}
+	}
+	for i := range exclusions {
+		exclusions[i].Target = Matcher{Mode: MatchExact, Value: target}
+	}
+	return exclusions, nil
+}
+
+// httpClient is the client reading the remote config files.
+var httpClient = &http.Client{
+	Timeout: time.Second * 5,
+}
+
+// readConfigFile reads a config file from a local path, a http(s) url or the
+// stdin if the path is "-".
+func readConfigFile(path string) ([]byte, error) {
+	switch {
+	case path == "-":
+		return ioutil.ReadAll(os.Stdin)
+	case isURL(path):
+		res, err := httpClient.Get(path)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %s", res.Status)
+		}
+		return ioutil.ReadAll(res.Body)
+	default:
+		return ioutil.ReadFile(path)
+	}
+}
+
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// resolveInclude returns the location of an included file relative to the
+// file including it.
+func resolveInclude(path, include string) string {
+	if isURL(include) || filepath.IsAbs(include) || path == "-" {
+		return include
+	}
+	if isURL(path) {
+		base, err := url.Parse(path)
+		if err != nil {
+			return include
+		}
+		ref, err := url.Parse(include)
+		if err != nil {
+			return include
+		}
+		return base.ResolveReference(ref).String()
+	}
+	return filepath.Join(filepath.Dir(path), include)
+}
+
 func AddRepo(cfg *Config, uri, alias string, l log.Logger) error {
 	var ct []Checktype
 	var err error
@@ -183,6 +470,16 @@ func AddRepo(cfg *Config, uri, alias string, l log.Logger) error {
 	return nil
 }
 
+// Hash returns the sha256 of the config files read, in order, after the
+// environment variables are replaced.
+func (cfg *Config) Hash() string {
+	h := sha256.New()
+	for _, src := range cfg.sources {
+		h.Write(src.content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func GetCheckById(cfg *Config, id string) *Check {
 	for i, c := range cfg.Checks {
 		if c.Id == id {
@@ -0,0 +1,407 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adevinta/vulcan-agent/log"
+)
+
+// writeFiles writes the files in the directory, creating the parent dirs.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadConfigIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"shared/base.yaml": `
+conf:
+  vars:
+    A: base
+    B: base
+reporting:
+  threshold: LOW
+  exclusions:
+    - summary: base
+checks:
+  - type: vulcan-base
+    target: base
+`,
+		"app/vulcan.yaml": `
+include:
+  - ../shared/base.yaml
+conf:
+  vars:
+    B: app
+    C: app
+reporting:
+  threshold: MEDIUM
+  exclusions:
+    - summary: app
+checks:
+  - type: vulcan-app
+    target: app
+`,
+	})
+
+	cfg := &Config{}
+	if err := ReadConfig(filepath.Join(dir, "app/vulcan.yaml"), cfg, &log.NullLog{}); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if want := map[string]string{"A": "base", "B": "app", "C": "app"}; !reflect.DeepEqual(cfg.Conf.Vars, want) {
+		t.Errorf("got vars %v, want %v", cfg.Conf.Vars, want)
+	}
+	if cfg.Reporting.Threshold != "MEDIUM" {
+		t.Errorf("got threshold %s, want MEDIUM", cfg.Reporting.Threshold)
+	}
+	checks := []string{}
+	for _, c := range cfg.Checks {
+		checks = append(checks, string(c.Type))
+	}
+	if want := []string{"vulcan-base", "vulcan-app"}; !reflect.DeepEqual(checks, want) {
+		t.Errorf("got checks %v, want %v", checks, want)
+	}
+	exclusions := []string{}
+	for _, e := range cfg.Reporting.Exclusions {
+		exclusions = append(exclusions, e.Summary.String())
+	}
+	if want := []string{"base", "app"}; !reflect.DeepEqual(exclusions, want) {
+		t.Errorf("got exclusions %v, want %v", exclusions, want)
+	}
+}
+
+func TestReadConfigURLIncludes(t *testing.T) {
+	files := map[string]string{
+		"/org/base.yaml": `
+include:
+  - nested/exclusions.yaml
+reporting:
+  threshold: LOW
+  outputFile: ${VL_TEST_SECRET}.json
+`,
+		"/org/nested/exclusions.yaml": `
+reporting:
+  exclusions:
+    - summary: nested
+`,
+		"/org/insecure.yaml": "include:\n  - http://example.com/base.yaml\n",
+	}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	defer srv.Close()
+	defer func(c *http.Client) { httpClient = c }(httpClient)
+	httpClient = srv.Client()
+	t.Setenv("VL_TEST_SECRET", "secret")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"vulcan.yaml":   fmt.Sprintf("include:\n  - %s/org/base.yaml\n", srv.URL),
+		"missing.yaml":  fmt.Sprintf("include:\n  - %s/org/missing.yaml\n", srv.URL),
+		"insecure.yaml": fmt.Sprintf("include:\n  - %s/org/insecure.yaml\n", srv.URL),
+		"http.yaml":     "include:\n  - http://example.com/base.yaml\n",
+	})
+	cfg := &Config{}
+	if err := ReadConfig(filepath.Join(dir, "vulcan.yaml"), cfg, &log.NullLog{}); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if cfg.Reporting.Threshold != "LOW" {
+		t.Errorf("got threshold %s, want LOW", cfg.Reporting.Threshold)
+	}
+	if cfg.Reporting.OutputFile != "${VL_TEST_SECRET}.json" {
+		t.Errorf("got outputFile %s, want the env var not substituted in remote files", cfg.Reporting.OutputFile)
+	}
+	if len(cfg.Reporting.Exclusions) != 1 || cfg.Reporting.Exclusions[0].Summary.String() != "nested" {
+		t.Fatalf("got exclusions %v, want the nested one", cfg.Reporting.Exclusions)
+	}
+	loc := cfg.Reporting.Exclusions[0].Locate()
+	if want := srv.URL + "/org/nested/exclusions.yaml"; loc.File != want || loc.Line != 4 {
+		t.Errorf("got location %s, want %s:4", loc, want)
+	}
+
+	for _, tt := range []struct {
+		file    string
+		wantErr string
+	}{
+		{"missing.yaml", "404"},
+		{"insecure.yaml", "insecure include http://example.com/base.yaml"},
+		{"http.yaml", "insecure include http://example.com/base.yaml"},
+	} {
+		if err := ReadConfig(filepath.Join(dir, tt.file), &Config{}, &log.NullLog{}); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: got error %v, want %q", tt.file, err, tt.wantErr)
+		}
+	}
+}
+
+func TestReadConfigEnv(t *testing.T) {
+	t.Setenv("VL_TEST_THRESHOLD", "CRITICAL")
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"vulcan.yaml": "reporting:\n  threshold: ${VL_TEST_THRESHOLD}\n"})
+	cfg := &Config{}
+	if err := ReadConfig(filepath.Join(dir, "vulcan.yaml"), cfg, &log.NullLog{}); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if cfg.Reporting.Threshold != "CRITICAL" {
+		t.Errorf("got threshold %s, want CRITICAL", cfg.Reporting.Threshold)
+	}
+}
+
+func TestReadConfigIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.yaml":     "include:\n  - b/b.yaml\n",
+		"b/b.yaml":   "include:\n  - c.yaml\n",
+		"b/c.yaml":   "include:\n  - ../a.yaml\n",
+		"d.yaml":     "include:\n  - d.yaml\n",
+		"e.yaml":     "include:\n  - f.yaml\n  - f.yaml\n",
+		"f.yaml":     "targets:\n  - target: f\n",
+		"g/one.yaml": "include:\n  - ../f.yaml\n",
+	})
+	a, b, c := filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b/b.yaml"), filepath.Join(dir, "b/c.yaml")
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{
+			name:    "through the stack",
+			path:    a,
+			wantErr: fmt.Sprintf("include cycle %s -> %s -> %s -> %s", a, b, c, a),
+		},
+		{
+			name:    "itself",
+			path:    filepath.Join(dir, "d.yaml"),
+			wantErr: "include cycle",
+		},
+		{
+			name: "repeated include",
+			path: filepath.Join(dir, "e.yaml"),
+		},
+		{
+			name: "relative to the including file",
+			path: filepath.Join(dir, "g/one.yaml"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ReadConfig(tt.path, &Config{}, &log.NullLog{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %+v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base.yaml": `reporting:
+  threshold: LOW
+  format: json
+checks:
+  - type: vulcan-base
+    target: base
+`,
+		"vulcan.yaml": `include:
+  - base.yaml
+reporting:
+  threshold: MEDIUM
+  exclusions:
+    - summary: foo
+      reason: bar
+checks:
+  - type: vulcan-zap
+    target: http://localhost
+    options:
+      depth: 1
+`,
+	})
+	base, main := filepath.Join(dir, "base.yaml"), filepath.Join(dir, "vulcan.yaml")
+	cfg := &Config{}
+	if err := ReadConfig(main, cfg, &log.NullLog{}); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  Location
+		want Location
+	}{
+		{"overridden value", cfg.Locate("reporting", "threshold"), Location{main, 4, 14}},
+		{"value of included file", cfg.Locate("reporting", "format"), Location{base, 3, 11}},
+		{"sequence index", cfg.Locate("checks", "0", "target"), Location{main, 10, 13}},
+		{"missing", cfg.Locate("reporting", "policy"), Location{}},
+		{"included check", cfg.Checks[0].Locate(), Location{base, 5, 5}},
+		{"check", cfg.Checks[1].Locate(), Location{main, 9, 5}},
+		{"check option", cfg.Checks[1].Locate("options", "depth"), Location{main, 12, 7}},
+		{"exclusion", cfg.Reporting.Exclusions[0].Locate(), Location{main, 6, 7}},
+		{"exclusion field", cfg.Reporting.Exclusions[0].Locate("reason"), Location{main, 7, 7}},
+		{"unlocated", (&Check{}).Locate(), Location{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadIgnoreFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content *string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "missing file",
+		},
+		{
+			name: "scoped to the target",
+			content: strPtr(`- summary: XSS
+  reason: false positive
+- fingerprint: {exact: abc}
+  target: other
+`),
+			want: []string{"XSS", "exact:abc"},
+		},
+		{
+			name:    "unknown field",
+			content: strPtr("- summary: XSS\n  reson: false positive\n"),
+			wantErr: `.vulcanignore:2:3: unknown field "reson" in [0], did you mean "reason"?`,
+		},
+		{
+			name:    "invalid date",
+			content: strPtr("- summary: XSS\n  expires: tomorrow\n"),
+			wantErr: `.vulcanignore:2:12: invalid date "tomorrow"`,
+		},
+		{
+			name:    "not a list",
+			content: strPtr("summary: XSS\n"),
+			wantErr: "unable to parse",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.content != nil {
+				writeFiles(t, dir, map[string]string{IgnoreFile: *tt.content})
+			}
+			exclusions, err := ReadIgnoreFile(dir, "git@github.com:org/repo.git")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %+v", err)
+			}
+			got := []string{}
+			for _, e := range exclusions {
+				got = append(got, e.Summary.String()+e.Fingerprint.String())
+				if e.Target.String() != "exact:git@github.com:org/repo.git" {
+					t.Errorf("got target %s, want the repository", e.Target)
+				}
+				if loc := e.Locate(); loc.File != filepath.Join(dir, IgnoreFile) {
+					t.Errorf("got location %s, want the ignore file", loc)
+				}
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("got exclusions %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestParseOutput(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Output
+	}{
+		{"results.json", Output{File: "results.json"}},
+		{"-", Output{File: "-"}},
+		{"sarif=x", Output{Format: "sarif", File: "x"}},
+		{"sarif=-", Output{Format: "sarif", File: "-"}},
+		{"json=out/a=b.json", Output{Format: "json", File: "out/a=b.json"}},
+		{"out/a=b.json", Output{File: "out/a=b.json"}},
+		{"./a=b.json", Output{File: "./a=b.json"}},
+		{`out\a=b.json`, Output{File: `out\a=b.json`}},
+		{"=results.json", Output{File: "=results.json"}},
+	}
+	for _, tt := range tests {
+		if got := ParseOutput(tt.value); got != tt.want {
+			t.Errorf("ParseOutput(%q)=%+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAllOutputs(t *testing.T) {
+	tests := []struct {
+		name      string
+		reporting Reporting
+		want      []Output
+	}{
+		{
+			name:      "none",
+			reporting: Reporting{Format: "json"},
+			want:      []Output{},
+		},
+		{
+			name:      "output file first",
+			reporting: Reporting{Format: "sarif", OutputFile: "a.sarif", Outputs: []Output{{Format: "junit", File: "a.xml"}}},
+			want:      []Output{{Format: "sarif", File: "a.sarif"}, {Format: "junit", File: "a.xml"}},
+		},
+		{
+			name:      "default format and unfiltered",
+			reporting: Reporting{Format: "csv", Unfiltered: true, Outputs: []Output{{File: "a.csv"}, {Format: "json", File: "-"}}},
+			want:      []Output{{Format: "csv", File: "a.csv", Unfiltered: true}, {Format: "json", File: "-", Unfiltered: true}},
+		},
+		{
+			name:      "unfiltered output",
+			reporting: Reporting{Outputs: []Output{{Format: "json", File: "all.json", Unfiltered: true}, {Format: "json", File: "a.json"}}},
+			want:      []Output{{Format: "json", File: "all.json", Unfiltered: true}, {Format: "json", File: "a.json"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.reporting.AllOutputs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
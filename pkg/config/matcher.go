@@ -0,0 +1,135 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Match modes of a Matcher.
+const (
+	MatchContains = "contains"
+	MatchExact    = "exact"
+	MatchRegex    = "regex"
+	MatchGlob     = "glob"
+)
+
+// MatchModes contains the valid match modes.
+var MatchModes = []string{MatchContains, MatchExact, MatchRegex, MatchGlob}
+
+// Matcher matches a string value of a vulnerability. In yaml it can be a
+// scalar, matched with contains, or a mapping with the mode as the key
+// (i.e. `target: {exact: http://localhost/}`).
+// A matcher without mode matches everything, and an invalid one nothing.
+type Matcher struct {
+	Mode  string
+	Value string
+	re    *regexp.Regexp
+	err   error // Reported with its location when validating the config
+}
+
+// NewMatcher returns a matcher of the mode.
+func NewMatcher(mode, value string) (Matcher, error) {
+	m := Matcher{Mode: mode, Value: value}
+	var err error
+	switch mode {
+	case MatchContains, MatchExact:
+	case MatchRegex:
+		m.re, err = regexp.Compile(value)
+	case MatchGlob:
+		m.re, err = regexp.Compile(globToRegexp(value))
+	default:
+		err = fmt.Errorf("invalid match mode %s, allowed values %v", mode, MatchModes)
+	}
+	return m, err
+}
+
+// parseMatcher returns the matcher of the yaml node.
+func parseMatcher(n *yaml.Node) (Matcher, error) {
+	switch {
+	case n.Kind == yaml.ScalarNode:
+		return NewMatcher(MatchContains, n.Value)
+	case n.Kind == yaml.MappingNode && len(n.Content) == 2 && n.Content[1].Kind == yaml.ScalarNode:
+		return NewMatcher(n.Content[0].Value, n.Content[1].Value)
+	}
+	return Matcher{}, fmt.Errorf("invalid matcher, expected a string or a mapping with one of %v", MatchModes)
+}
+
+// UnmarshalYAML doesn't fail on invalid matchers so the rest of the config
+// is validated, their errors are reported by checkNode.
+func (m *Matcher) UnmarshalYAML(n *yaml.Node) error {
+	matcher, err := parseMatcher(n)
+	matcher.err = err
+	*m = matcher
+	return nil
+}
+
+func (m *Matcher) checkNode(n *yaml.Node) error {
+	_, err := parseMatcher(n)
+	return err
+}
+
+// IsZero returns true if the matcher matches everything.
+func (m Matcher) IsZero() bool {
+	return m.Mode == "" && m.err == nil
+}
+
+// Match returns true if the value matches.
+func (m *Matcher) Match(s string) bool {
+	if m.err != nil {
+		return false
+	}
+	switch m.Mode {
+	case "":
+		return true
+	case MatchContains:
+		return strings.Contains(s, m.Value)
+	case MatchExact:
+		return s == m.Value
+	case MatchRegex, MatchGlob:
+		if m.re == nil {
+			matcher, err := NewMatcher(m.Mode, m.Value)
+			if err != nil {
+				return false
+			}
+			m.re = matcher.re
+		}
+		return m.re.MatchString(s)
+	}
+	return false
+}
+
+func (m Matcher) String() string {
+	switch m.Mode {
+	case "":
+		return ""
+	case MatchContains:
+		return m.Value
+	}
+	return fmt.Sprintf("%s:%s", m.Mode, m.Value)
+}
+
+// globToRegexp returns the regexp matching the whole string with the glob
+// pattern, where * matches any sequence of characters and ? any character.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMatcherUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    Matcher
+		wantErr bool
+	}{
+		{"scalar", `foo`, Matcher{Mode: MatchContains, Value: "foo"}, false},
+		{"number scalar", `8080`, Matcher{Mode: MatchContains, Value: "8080"}, false},
+		{"exact", `{exact: http://localhost/}`, Matcher{Mode: MatchExact, Value: "http://localhost/"}, false},
+		{"regex", `{regex: "^CVE-\\d+"}`, Matcher{Mode: MatchRegex, Value: `^CVE-\d+`}, false},
+		{"glob", `{glob: "*.example.com"}`, Matcher{Mode: MatchGlob, Value: "*.example.com"}, false},
+		{"unknown mode", `{exactly: foo}`, Matcher{Mode: "exactly", Value: "foo"}, true},
+		{"invalid regex", `{regex: "("}`, Matcher{Mode: MatchRegex, Value: "("}, true},
+		{"several modes", `{exact: foo, regex: bar}`, Matcher{}, true},
+		{"sequence", `[foo]`, Matcher{}, true},
+		{"mapping value", `{exact: {foo: bar}}`, Matcher{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Matcher
+			if err := yaml.Unmarshal([]byte(tt.yaml), &m); err != nil {
+				t.Fatalf("unexpected error %+v", err)
+			}
+			if m.Mode != tt.want.Mode || m.Value != tt.want.Value {
+				t.Errorf("got %s:%s, want %s:%s", m.Mode, m.Value, tt.want.Mode, tt.want.Value)
+			}
+			if (m.err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", m.err, tt.wantErr)
+			}
+			if tt.wantErr && (m.IsZero() || m.Match("foo")) {
+				t.Errorf("invalid matcher must not match")
+			}
+		})
+	}
+}
+
+func TestMatcherMatch(t *testing.T) {
+	tests := []struct {
+		mode  string
+		value string
+		s     string
+		want  bool
+	}{
+		{MatchContains, "localhost", "http://localhost:8080/", true},
+		{MatchContains, "localhost", "http://example.com/", false},
+		{MatchContains, "", "anything", true},
+		{MatchExact, "http://localhost/", "http://localhost/", true},
+		{MatchExact, "http://localhost/", "http://localhost/app", false},
+		{MatchRegex, `^CVE-2021-\d+$`, "CVE-2021-44228", true},
+		{MatchRegex, `CVE-2021`, "Log4Shell CVE-2021-44228", true},
+		{MatchRegex, `^CVE-2021-\d+$`, "CVE-2022-0001", false},
+		{MatchGlob, "*.example.com", "api.example.com", true},
+		{MatchGlob, "*.example.com", "example.com", false},
+		{MatchGlob, "*.example.com", "api.example.com.evil", false},
+		{MatchGlob, "lib?.so", "libc.so", true},
+		{MatchGlob, "lib?.so", "libxx.so", false},
+		{MatchGlob, "a.b", "axb", false},
+		{MatchGlob, "[a]*", "[a]bc", true},
+	}
+	for _, tt := range tests {
+		m, err := NewMatcher(tt.mode, tt.value)
+		if err != nil {
+			t.Fatalf("NewMatcher(%s, %s) unexpected error %+v", tt.mode, tt.value, err)
+		}
+		if got := m.Match(tt.s); got != tt.want {
+			t.Errorf("%s:%s Match(%q)=%v, want %v", tt.mode, tt.value, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestMatcherZero(t *testing.T) {
+	var m Matcher
+	if !m.IsZero() || !m.Match("anything") || m.String() != "" {
+		t.Errorf("the zero matcher must match everything")
+	}
+	if _, err := NewMatcher("exactly", "foo"); err == nil {
+		t.Errorf("NewMatcher with unknown mode must fail")
+	}
+}
+
+func TestMatcherString(t *testing.T) {
+	tests := []struct {
+		mode, value, want string
+	}{
+		{MatchContains, "foo", "foo"},
+		{MatchExact, "foo", "exact:foo"},
+		{MatchGlob, "*.foo", "glob:*.foo"},
+	}
+	for _, tt := range tests {
+		m, _ := NewMatcher(tt.mode, tt.value)
+		if got := m.String(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PullPolicies contains the valid values for pullPolicy.
+var PullPolicies = []string{"Always", "IfNotPresent", "Never"}
+
+// Location is a position in a config file.
+type Location struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (l Location) String() string {
+	if l.File == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d:%d", l.File, l.Line, l.Column)
+}
+
+// Problem is an issue found validating the config.
+type Problem struct {
+	Location
+	Warning bool
+	Message string
+}
+
+func (p Problem) String() string {
+	if loc := p.Location.String(); loc != "" {
+		return fmt.Sprintf("%s: %s", loc, p.Message)
+	}
+	return p.Message
+}
+
+// HasErrors returns true if any of the problems is not a warning.
+func HasErrors(problems []Problem) bool {
+	for _, p := range problems {
+		if !p.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeRef is a yaml node of a config file.
+type nodeRef struct {
+	file    string
+	node    *yaml.Node
+	content []byte // Only in the config files
+}
+
+// lookup returns the node of the value in the path of mapping keys.
+func (r nodeRef) lookup(keys ...string) *yaml.Node {
+	k, v := r.lookupKey(keys...)
+	if k == nil {
+		return nil
+	}
+	return v
+}
+
+// lookupKey returns the key and value nodes in the path of mapping keys or
+// sequence indexes. The key of a sequence item is the item itself.
+func (r nodeRef) lookupKey(keys ...string) (*yaml.Node, *yaml.Node) {
+	n := r.node
+	if n != nil && n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	var key *yaml.Node
+	for _, k := range keys {
+		if n != nil && n.Kind == yaml.SequenceNode {
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(n.Content) {
+				return nil, nil
+			}
+			key, n = n.Content[i], n.Content[i]
+			continue
+		}
+		if n == nil || n.Kind != yaml.MappingNode {
+			return nil, nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == k {
+				key, next = n.Content[i], n.Content[i+1]
+			}
+		}
+		if next == nil {
+			return nil, nil
+		}
+		n = next
+	}
+	return key, n
+}
+
+func (r nodeRef) location(n *yaml.Node) Location {
+	if n == nil {
+		return Location{}
+	}
+	return Location{File: r.file, Line: n.Line, Column: n.Column}
+}
+
+// Locate returns the location of the value in the path of keys in the last
+// config file defining it.
+func (cfg *Config) Locate(keys ...string) Location {
+	for i := len(cfg.sources) - 1; i >= 0; i-- {
+		if n := cfg.sources[i].lookup(keys...); n != nil {
+			return cfg.sources[i].location(n)
+		}
+	}
+	return Location{}
+}
+
+// Locate returns the location of the check or of the value in the path of keys
+// of the check.
+func (c *Check) Locate(keys ...string) Location {
+	if c.node.node == nil {
+		return Location{}
+	}
+	if len(keys) == 0 {
+		return c.node.location(c.node.node)
+	}
+	k, _ := c.node.lookupKey(keys...)
+	return c.node.location(k)
+}
+
+// Locate returns the location of the exclusion or of the value in the path of
+// keys of the exclusion.
+func (e *Exclusion) Locate(keys ...string) Location {
+	if e.node.node == nil {
+		return Location{}
+	}
+	if len(keys) == 0 {
+		return e.node.location(e.node.node)
+	}
+	k, _ := e.node.lookupKey(keys...)
+	return e.node.location(k)
+}
+
+// Validate checks the config files only contain known fields and the
+// values that can be validated without the checktypes are valid.
+func (cfg *Config) Validate() []Problem {
+	problems := []Problem{}
+	for _, src := range cfg.sources {
+		n := src.node
+		if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+			n = n.Content[0]
+		}
+		problems = append(problems, checkFields(src, n, reflect.TypeOf(cfg).Elem(), "")...)
+	}
+
+	policy := strings.TrimSpace(cfg.Conf.PullPolicy)
+	valid := false
+	for _, p := range PullPolicies {
+		if strings.EqualFold(policy, p) {
+			valid = true
+		}
+	}
+	if !valid {
+		problems = append(problems, Problem{
+			Location: cfg.Locate("conf", "pullPolicy"),
+			Message:  fmt.Sprintf("invalid pullPolicy %q, allowed values %v", cfg.Conf.PullPolicy, PullPolicies),
+		})
+	}
+
+	switch cfg.Reporting.OnCheckError {
+	case "", OnCheckErrorFail, OnCheckErrorWarn, OnCheckErrorIgnore:
+	default:
+		problems = append(problems, Problem{
+			Location: cfg.Locate("reporting", "onCheckError"),
+			Message: fmt.Sprintf("invalid onCheckError %q, allowed values [%s %s %s]", cfg.Reporting.OnCheckError,
+				OnCheckErrorFail, OnCheckErrorWarn, OnCheckErrorIgnore),
+		})
+	}
+
+	if cfg.Reporting.MarkdownMaxSize < 0 {
+		problems = append(problems, Problem{
+			Location: cfg.Locate("reporting", "markdownMaxSize"),
+			Message:  fmt.Sprintf("invalid markdownMaxSize %d, it must be positive", cfg.Reporting.MarkdownMaxSize),
+		})
+	}
+
+	for _, r := range []struct {
+		name  string
+		value string
+	}{{"include", cfg.Conf.Include}, {"exclude", cfg.Conf.Exclude}} {
+		if r.value == "" {
+			continue
+		}
+		if _, err := regexp.Compile(r.value); err != nil {
+			problems = append(problems, Problem{
+				Location: cfg.Locate("conf", r.name),
+				Message:  fmt.Sprintf("invalid %s regexp %q %v", r.name, r.value, err),
+			})
+		}
+	}
+	return problems
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// nodeChecker is implemented by the types whose UnmarshalYAML doesn't fail
+// on invalid values, to report them with their location.
+type nodeChecker interface {
+	checkNode(n *yaml.Node) error
+}
+
+// checkFields returns a problem for each key in the node not defined in the
+// type and for each invalid value of the types implementing nodeChecker.
+func checkFields(src nodeRef, n *yaml.Node, t reflect.Type, path string) []Problem {
+	if n == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		c, ok := reflect.New(t).Interface().(nodeChecker)
+		if !ok || n.ShortTag() == "!!null" {
+			return nil
+		}
+		if err := c.checkNode(n); err != nil {
+			return []Problem{{Location: src.location(n), Message: fmt.Sprintf("%s in %s", err, path)}}
+		}
+		return nil
+	}
+	problems := []Problem{}
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			f, ok := fields[key.Value]
+			if !ok {
+				problems = append(problems, Problem{
+					Location: src.location(key),
+					Message:  unknownFieldMessage(key.Value, path, fields),
+				})
+				continue
+			}
+			problems = append(problems, checkFields(src, value, f.Type, joinPath(path, key.Value))...)
+		}
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, item := range n.Content {
+			problems = append(problems, checkFields(src, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			problems = append(problems, checkFields(src, n.Content[i+1], t.Elem(), joinPath(path, n.Content[i].Value))...)
+		}
+	}
+	return problems
+}
+
+// yamlFields returns the fields of the struct indexed by their yaml key.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" { // Unexported
+			continue
+		}
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func unknownFieldMessage(key, path string, fields map[string]reflect.StructField) string {
+	msg := fmt.Sprintf("unknown field %q", key)
+	if path != "" {
+		msg = fmt.Sprintf("%s in %s", msg, path)
+	}
+	names := []string{}
+	for name := range fields {
+		names = append(names, name)
+	}
+	if best := Suggest(key, names); best != "" {
+		msg = fmt.Sprintf("%s, did you mean %q?", msg, best)
+	}
+	return msg
+}
+
+// Suggest returns the candidate closest to the name, or empty if none of them
+// is similar enough.
+func Suggest(name string, candidates []string) string {
+	best, distance := "", len(name)/2+1
+	for _, c := range candidates {
+		if d := levenshtein(strings.ToLower(name), strings.ToLower(c)); d < distance || (d == distance && c < best) {
+			best, distance = c, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
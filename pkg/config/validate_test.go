@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/adevinta/vulcan-agent/log"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{
+			name: "valid",
+			config: `conf:
+  pullPolicy: IfNotPresent
+reporting:
+  threshold: HIGH
+  exclusions:
+    - summary: {glob: "XSS*"}
+      expires: 2030-01-02
+`,
+			want: []string{},
+		},
+		{
+			name: "unknown fields",
+			config: `conf:
+  pullPolicy: Always
+reporting:
+  treshold: HIGH
+checks:
+  - type: vulcan-zap
+    assettype: WebAddress
+`,
+			want: []string{
+				`4:3: unknown field "treshold" in reporting, did you mean "threshold"?`,
+				`7:5: unknown field "assettype" in checks[0], did you mean "assetType"?`,
+			},
+		},
+		{
+			name: "unknown field without suggestion",
+			config: `conf:
+  pullPolicy: Always
+foo: bar
+`,
+			want: []string{`3:1: unknown field "foo"`},
+		},
+		{
+			name: "invalid values",
+			config: `conf:
+  pullPolicy: Sometimes
+  include: "("
+reporting:
+  onCheckError: panic
+  markdownMaxSize: -1
+`,
+			want: []string{
+				`2:15: invalid pullPolicy "Sometimes", allowed values [Always IfNotPresent Never]`,
+				`5:17: invalid onCheckError "panic", allowed values [fail warn ignore]`,
+				`6:20: invalid markdownMaxSize -1, it must be positive`,
+				"3:12: invalid include regexp \"(\" error parsing regexp: missing closing ): `(`",
+			},
+		},
+		{
+			name: "invalid dates and matchers",
+			config: `conf:
+  pullPolicy: Always
+reporting:
+  exclusions:
+    - summary: {exactly: foo}
+      expires: 2021-13-45
+    - target: [a, b]
+    - fingerprint: foo
+      expires:
+`,
+			want: []string{
+				`5:16: invalid match mode exactly, allowed values [contains exact regex glob] in reporting.exclusions[0].summary`,
+				`6:16: invalid date "2021-13-45", expected YYYY-MM-DD in reporting.exclusions[0].expires`,
+				`7:15: invalid matcher, expected a string or a mapping with one of [contains exact regex glob] in reporting.exclusions[1].target`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "vulcan.yaml")
+			writeFiles(t, filepath.Dir(path), map[string]string{"vulcan.yaml": tt.config})
+			cfg := &Config{}
+			if err := ReadConfig(path, cfg, &log.NullLog{}); err != nil {
+				t.Fatalf("unexpected error %+v", err)
+			}
+			got := []string{}
+			for _, p := range cfg.Validate() {
+				if p.File != path {
+					t.Errorf("got file %s, want %s", p.File, path)
+				}
+				got = append(got, fmt.Sprintf("%d:%d: %s", p.Line, p.Column, p.Message))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got problems\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"threshold", "format", "outputFile", "exclusions", "assetType"}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"treshold", "threshold"},
+		{"Threshold", "threshold"},
+		{"assettype", "assetType"},
+		{"fromat", "format"},
+		{"output", ""}, // Too many edits for its length
+		{"foo", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Suggest(tt.name, candidates); got != tt.want {
+			t.Errorf("Suggest(%q)=%q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"treshold", "threshold", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q)=%d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
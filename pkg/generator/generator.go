@@ -13,7 +13,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -26,7 +28,8 @@ import (
 	"github.mpi-internal.com/spt-security/vulcan-local/pkg/gitservice"
 )
 
-func getCheckType(cfg *config.Config, checkTypeRef config.ChecktypeRef) (*config.Checktype, error) {
+// GetCheckType returns the checktype referenced with an optional repository prefix (i.e. default/vulcan-zap or vulcan-zap).
+func GetCheckType(cfg *config.Config, checkTypeRef config.ChecktypeRef) (*config.Checktype, error) {
 	names := strings.Split(string(checkTypeRef), "/")
 	repo := "default"
 	name := names[0]
@@ -54,7 +57,7 @@ func mergeOptions(optsA map[string]interface{}, optsB map[string]interface{}) ma
 }
 
 // buildOptions generates a string encoded Now it makes the union of both options with precedence of the targetOpts.
-// TODO: Decide if it makes sense to intersect, discarding the target options not defined in the checktypeOpts
+// The target options not defined in the checktypeOpts are kept, see ValidateOptions.
 func buildOptions(checktypeOpts, targetOpts map[string]interface{}) (string, error) {
 	totalOptions := map[string]interface{}{}
 	if len(checktypeOpts) > 0 {
@@ -70,24 +73,101 @@ func buildOptions(checktypeOpts, targetOpts map[string]interface{}) (string, err
 	return string(content), nil
 }
 
+// OptionError is an invalid option of a check.
+type OptionError struct {
+	Option  string
+	Message string
+}
+
+func (e OptionError) Error() string {
+	return fmt.Sprintf("option %s %s", e.Option, e.Message)
+}
+
+// ValidateOptions checks the options of a check are defined by the checktype
+// and have the same type as their defaults.
+func ValidateOptions(checktypeOpts, opts map[string]interface{}) []OptionError {
+	names := []string{}
+	for k := range checktypeOpts {
+		names = append(names, k)
+	}
+	keys := []string{}
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	errs := []OptionError{}
+	for _, k := range keys {
+		def, ok := checktypeOpts[k]
+		if !ok {
+			msg := "is not defined by the checktype"
+			if s := config.Suggest(k, names); s != "" {
+				msg = fmt.Sprintf("%s, did you mean %s?", msg, s)
+			}
+			errs = append(errs, OptionError{Option: k, Message: msg})
+			continue
+		}
+		expected, actual := optionKind(def), optionKind(opts[k])
+		if expected != "" && actual != "" && expected != actual {
+			errs = append(errs, OptionError{Option: k, Message: fmt.Sprintf("is %s, expected %s", actual, expected)})
+		}
+	}
+	return errs
+}
+
+// optionKind returns the json type of an option value, or empty for null.
+func optionKind(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return ""
+}
+
 func GenerateJobs(cfg *config.Config, agentIp, hostIp string, gs gitservice.GitService, l log.Logger) ([]jobrunner.Job, error) {
 	jobs := []jobrunner.Job{}
+	ignored := map[string]bool{} // Targets with the ignore file already loaded
 	for i := range cfg.Checks {
 
 		// Because We want to update the original Check
 		c := &cfg.Checks[i]
 
-		ch, err := getCheckType(cfg, c.Type)
+		ch, err := GetCheckType(cfg, c.Type)
 		if err != nil {
 			l.Errorf("Skipping check - %s", err)
 			continue
 		}
 
-		if !filterChecktype(ch.Name, cfg.Conf.IncludeR, cfg.Conf.ExcludeR) {
+		if !FilterChecktype(ch.Name, cfg.Conf.IncludeR, cfg.Conf.ExcludeR) {
 			l.Debugf("Skipping filtered check=%s", ch.Name)
 			continue
 		}
 
+		// The options of the checks in the config are reported when validating it,
+		// and the target options are shared by all the checktypes.
+		if errs := ValidateOptions(ch.Options, c.Options); len(errs) > 0 {
+			msgs := []string{}
+			for _, e := range errs {
+				l.Debugf("Invalid option check=%s target=%s - %s", ch.Name, c.Target, e)
+				msgs = append(msgs, e.Error())
+			}
+			if c.StrictOptions {
+				return nil, fmt.Errorf("invalid options check=%s target=%s - %s", ch.Name, c.Target, strings.Join(msgs, ", "))
+			}
+		}
+
 		ops, err := buildOptions(ch.Options, c.Options)
 		if err != nil {
 			l.Errorf("Skipping check - %s", err)
@@ -95,7 +175,7 @@ func GenerateJobs(cfg *config.Config, agentIp, hostIp string, gs gitservice.GitS
 		}
 		c.Id = uuid.New().String()
 		c.NewTarget = c.Target
-		if stringInSlice("GitRepository", ch.Assets) {
+		if StringInSlice("GitRepository", ch.Assets) {
 			if path, err := GetValidGitDirectory(c.Target); err == nil {
 				c.AssetType = "GitRepository"
 				port, err := gs.AddGit(path)
@@ -104,6 +184,9 @@ func GenerateJobs(cfg *config.Config, agentIp, hostIp string, gs gitservice.GitS
 					continue
 				}
 				c.NewTarget = fmt.Sprintf("http://%s:%d/", agentIp, port)
+				if err := loadIgnoreFile(cfg, path, c.Target, ignored, l); err != nil {
+					return nil, err
+				}
 			}
 		}
 		m1 := regexp.MustCompile(`(?i)(localhost|127.0.0.1)`)
@@ -128,7 +211,45 @@ func GenerateJobs(cfg *config.Config, agentIp, hostIp string, gs gitservice.GitS
 	return jobs, nil
 }
 
-func stringInSlice(a string, list []string) bool {
+// loadIgnoreFile adds the exclusions in the ignore file of the repository of
+// the target, unless they are already loaded.
+func loadIgnoreFile(cfg *config.Config, path, target string, loaded map[string]bool, l log.Logger) error {
+	if loaded[target] {
+		return nil
+	}
+	exclusions, err := config.ReadIgnoreFile(path, target)
+	if err != nil {
+		return err
+	}
+	if len(exclusions) > 0 {
+		l.Infof("Loaded exclusions file=%s target=%s exclusions=%d", config.IgnoreFile, target, len(exclusions))
+	}
+	cfg.Reporting.Exclusions = append(cfg.Reporting.Exclusions, exclusions...)
+	loaded[target] = true
+	return nil
+}
+
+// LoadIgnoreFiles adds the exclusions in the ignore files of the local git
+// repositories scanned by the checks, i.e. when rendering a saved scan.
+func LoadIgnoreFiles(cfg *config.Config, l log.Logger) error {
+	loaded := map[string]bool{}
+	for _, c := range cfg.Checks {
+		if c.AssetType != "GitRepository" {
+			continue
+		}
+		path, err := GetValidGitDirectory(c.Target)
+		if err != nil {
+			continue
+		}
+		if err := loadIgnoreFile(cfg, path, c.Target, loaded, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StringInSlice returns true if the list contains the string.
+func StringInSlice(a string, list []string) bool {
 	for _, b := range list {
 		if b == a {
 			return true
@@ -242,7 +363,7 @@ func getTypesFromIdentifier(target config.Target) ([]config.Target, error) {
 // and generates the list of checks to run based on the available Checktypes and AssetType
 func GenerateChecksFromTargets(cfg *config.Config, l log.Logger) error {
 	// Generate a new list of Targets with AssetType
-	expandedTargets := []config.Target{}
+	expandedTargets := [][]config.Target{} // expanded targets of each target
 	for _, t := range cfg.Targets {
 		if t.AssetType == "" {
 			// Try to infer the asset type
@@ -252,30 +373,92 @@ func GenerateChecksFromTargets(cfg *config.Config, l log.Logger) error {
 				for _, a := range inferredTargets {
 					l.Debugf("Inferred asset type target=%s assetType=%s", a.Target, a.AssetType)
 				}
-				expandedTargets = append(expandedTargets, inferredTargets...)
+				expandedTargets = append(expandedTargets, inferredTargets)
 			}
 		} else {
-			expandedTargets = append(expandedTargets, t)
+			expandedTargets = append(expandedTargets, []config.Target{t})
 		}
 	}
 
 	// Generate checks of unique targets (target + assettype + options)
-	uniq := map[string]interface{}{}  // controls duplicates
-	dedupTargets := []config.Target{} // new list of unique targets
-	for _, a := range expandedTargets {
-		f := ComputeFingerprint(a)
-		if _, ok := uniq[f]; ok {
-			l.Debugf("Skipping duplicated target %v", a)
-		} else {
-			dedupTargets = append(dedupTargets, a)
-			uniq[f] = nil
-			AddAssetChecks(cfg, a, l)
+	uniq := map[string][]config.Check{} // controls duplicates with the checks generated
+	dedupTargets := []config.Target{}   // new list of unique targets
+	for i, targets := range expandedTargets {
+		checks := []config.Check{}
+		for _, a := range targets {
+			f := ComputeFingerprint(a)
+			if generated, ok := uniq[f]; ok {
+				l.Debugf("Skipping duplicated target %v", a)
+				checks = append(checks, generated...)
+			} else {
+				dedupTargets = append(dedupTargets, a)
+				n := len(cfg.Checks)
+				AddAssetChecks(cfg, a, l)
+				uniq[f] = cfg.Checks[n:]
+				checks = append(checks, cfg.Checks[n:]...)
+			}
+		}
+
+		// The target options are shared by all its checks, so only the ones
+		// unknown to all the checktypes are reported.
+		t := cfg.Targets[i]
+		for _, e := range UnknownTargetOptions(cfg, t.Options, checks) {
+			warnf(l, "Invalid target option target=%s - %s", t.Target, e)
 		}
 	}
 	cfg.Targets = dedupTargets
 	return nil
 }
 
+// UnknownTargetOptions returns an error for each option of a target not
+// defined by any of the checktypes of its checks.
+func UnknownTargetOptions(cfg *config.Config, opts map[string]interface{}, checks []config.Check) []OptionError {
+	if len(checks) == 0 {
+		return nil
+	}
+	defined := map[string]interface{}{}
+	for _, c := range checks {
+		ch, err := GetCheckType(cfg, c.Type)
+		if err != nil {
+			continue
+		}
+		for k, v := range ch.Options {
+			defined[k] = v
+		}
+	}
+	names := []string{}
+	for k := range defined {
+		names = append(names, k)
+	}
+	keys := []string{}
+	for k := range opts {
+		if _, ok := defined[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	errs := []OptionError{}
+	for _, k := range keys {
+		msg := "is not defined by any checktype of the target"
+		if s := config.Suggest(k, names); s != "" {
+			msg = fmt.Sprintf("%s, did you mean %s?", msg, s)
+		}
+		errs = append(errs, OptionError{Option: k, Message: msg})
+	}
+	return errs
+}
+
+// warnf logs at warning level when the logger supports it.
+func warnf(l log.Logger, format string, args ...interface{}) {
+	if w, ok := l.(interface {
+		Warnf(string, ...interface{})
+	}); ok {
+		w.Warnf(format, args...)
+		return
+	}
+	l.Infof(format, args...)
+}
+
 func ComputeFingerprint(args ...interface{}) string {
 	h := sha256.New()
 
@@ -289,7 +472,7 @@ func ComputeFingerprint(args ...interface{}) string {
 func AddAssetChecks(cfg *config.Config, a config.Target, l log.Logger) error {
 	checks := []config.Check{}
 	for ref, ch := range cfg.CheckTypes {
-		if stringInSlice(a.AssetType, ch.Assets) && filterChecktype(ch.Name, cfg.Conf.IncludeR, cfg.Conf.ExcludeR) {
+		if StringInSlice(a.AssetType, ch.Assets) && FilterChecktype(ch.Name, cfg.Conf.IncludeR, cfg.Conf.ExcludeR) {
 			checks = append(checks, config.Check{
 				Type:      ref,
 				Target:    a.Target,
@@ -318,7 +501,8 @@ func SendJobs(jobs []jobrunner.Job, arn, endpoint string, l log.Logger) error {
 	return nil
 }
 
-func filterChecktype(name string, include, exclude *regexp.Regexp) bool {
+// FilterChecktype returns true if the checktype name matches the include regex or doesn't match the exclude one.
+func FilterChecktype(name string, include, exclude *regexp.Regexp) bool {
 	if include != nil {
 		return include.Match([]byte(name))
 	}
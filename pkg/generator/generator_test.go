@@ -0,0 +1,172 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package generator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
+)
+
+type testLogger struct {
+	warnings []string
+}
+
+func (l *testLogger) Debugf(format string, args ...interface{}) {}
+func (l *testLogger) Infof(format string, args ...interface{})  {}
+func (l *testLogger) Errorf(format string, args ...interface{}) {}
+func (l *testLogger) Warnf(format string, args ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
+
+func testConfig(targets ...config.Target) *config.Config {
+	return &config.Config{
+		Targets: targets,
+		CheckTypes: map[config.ChecktypeRef]config.Checktype{
+			"default/vulcan-zap": {
+				Name:    "vulcan-zap",
+				Assets:  []string{"WebAddress"},
+				Options: map[string]interface{}{"depth": 2, "max_scan_duration": 10},
+			},
+			"default/vulcan-http-headers": {
+				Name:   "vulcan-http-headers",
+				Assets: []string{"WebAddress"},
+			},
+			"default/vulcan-nuclei": {
+				Name:    "vulcan-nuclei",
+				Assets:  []string{"Hostname"},
+				Options: map[string]interface{}{"tags": ""},
+			},
+		},
+	}
+}
+
+func TestUnknownTargetOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    map[string]interface{}
+		checks  []config.Check
+		want    []string
+		suggest string
+	}{
+		{
+			name:   "defined by one checktype",
+			opts:   map[string]interface{}{"depth": 1},
+			checks: []config.Check{{Type: "vulcan-http-headers"}, {Type: "vulcan-zap"}},
+		},
+		{
+			name:    "misspelled",
+			opts:    map[string]interface{}{"max_scan_duraton": 1, "depth": 1},
+			checks:  []config.Check{{Type: "vulcan-zap"}},
+			want:    []string{"max_scan_duraton"},
+			suggest: "max_scan_duration",
+		},
+		{
+			name:   "defined by a checktype of other asset type",
+			opts:   map[string]interface{}{"tags": "cve"},
+			checks: []config.Check{{Type: "vulcan-zap"}},
+			want:   []string{"tags"},
+		},
+		{
+			name: "without checks",
+			opts: map[string]interface{}{"depth": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := UnknownTargetOptions(testConfig(), tt.opts, tt.checks)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("got %v, want options %v", errs, tt.want)
+			}
+			for i, e := range errs {
+				if e.Option != tt.want[i] {
+					t.Errorf("got option %s, want %s", e.Option, tt.want[i])
+				}
+				if tt.suggest != "" && !strings.Contains(e.Message, tt.suggest) {
+					t.Errorf("got message %q, want suggestion %s", e.Message, tt.suggest)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateChecksFromTargetsWarnsUnknownOptions(t *testing.T) {
+	cfg := testConfig(
+		config.Target{Target: "http://example.com", AssetType: "WebAddress", Options: map[string]interface{}{"max_scan_duraton": 1}},
+		config.Target{Target: "example.com", AssetType: "Hostname", Options: map[string]interface{}{"tags": "cve"}},
+	)
+	l := &testLogger{}
+	if err := GenerateChecksFromTargets(cfg, l); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if len(cfg.Checks) != 3 {
+		t.Errorf("got %d checks, want 3", len(cfg.Checks))
+	}
+	if len(l.warnings) != 1 {
+		t.Fatalf("got warnings %v, want 1", l.warnings)
+	}
+	if w := l.warnings[0]; !strings.Contains(w, "target=http://example.com") || !strings.Contains(w, "max_scan_duraton") {
+		t.Errorf("unexpected warning %q", w)
+	}
+}
+
+func TestLoadIgnoreFiles(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(repo, config.IgnoreFile), []byte("- summary: Leaked\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig()
+	cfg.Checks = []config.Check{
+		{Type: "vulcan-seekret", Target: repo, AssetType: "GitRepository"},
+		{Type: "vulcan-gitleaks", Target: repo, AssetType: "GitRepository"},
+		{Type: "vulcan-seekret", Target: t.TempDir(), AssetType: "GitRepository"}, // Without .git
+		{Type: "vulcan-zap", Target: repo, AssetType: "WebAddress"},
+	}
+	if err := LoadIgnoreFiles(cfg, &testLogger{}); err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if len(cfg.Reporting.Exclusions) != 1 {
+		t.Fatalf("got exclusions %+v, want 1", cfg.Reporting.Exclusions)
+	}
+	if e := cfg.Reporting.Exclusions[0]; e.Summary.String() != "Leaked" || e.Target.String() != "exact:"+repo {
+		t.Errorf("unexpected exclusion %+v", e)
+	}
+}
+
+func TestGenerateJobsStrictOptions(t *testing.T) {
+	cfg := testConfig()
+	cfg.Checks = []config.Check{{
+		Type:          "vulcan-zap",
+		Target:        "http://example.com",
+		Options:       map[string]interface{}{"max_scan_duraton": 1, "depth": "2"},
+		StrictOptions: true,
+	}}
+	_, err := GenerateJobs(cfg, "172.17.0.1", "172.17.0.1", nil, &testLogger{})
+	if err == nil {
+		t.Fatal("expected an error with strict options")
+	}
+	for _, s := range []string{"check=vulcan-zap", "option depth is string, expected number", "option max_scan_duraton"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("got error %q, want %q", err, s)
+		}
+	}
+
+	cfg.Checks[0].StrictOptions = false
+	jobs, err := GenerateJobs(cfg, "172.17.0.1", "172.17.0.1", nil, &testLogger{})
+	if err != nil {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if len(jobs) != 1 {
+		t.Errorf("got %d jobs, want 1", len(jobs))
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package reporting
+
+import (
+	"fmt"
+
+	report "github.com/adevinta/vulcan-report"
+)
+
+// baseline contains the vulnerabilities of a previous report indexed by
+// vulnerabilityKey.
+type baseline map[string]struct{}
+
+// baselineStats contains the result of comparing the current vulnerabilities
+// with the baseline.
+type baselineStats struct {
+	New   int
+	Known int
+	Fixed int
+}
+
+// vulnerabilityKey identifies a vulnerability of a target by its fingerprint,
+// or by the checktype, summary and affected resource when the check doesn't
+// set it, so the vulnerabilities without fingerprint don't match each other.
+func vulnerabilityKey(checktype, target string, v *report.Vulnerability) string {
+	if v.Fingerprint != "" {
+		return fmt.Sprintf("%s|%s", target, v.Fingerprint)
+	}
+	return fmt.Sprintf("%s||%s|%s|%s", target, checktype, v.Summary, affectedResource(v))
+}
+
+// ReadReport reads the reports in a json or vulcan-local report written by
+// Generate.
+func ReadReport(path string) ([]report.Report, error) {
+	saved, err := ReadSavedRun(path)
+	if err != nil {
+		return nil, err
+	}
+	return saved.Reports, nil
+}
+
+func loadBaseline(path string) (baseline, error) {
+	reports, err := ReadReport(path)
+	if err != nil {
+		return nil, err
+	}
+	b := baseline{}
+	for _, r := range reports {
+		for _, v := range r.Vulnerabilities {
+			b[vulnerabilityKey(r.ChecktypeName, r.Target, &v)] = struct{}{}
+		}
+	}
+	return b, nil
+}
+
+// contains returns true if the vulnerability was present in the baseline.
+func (b baseline) contains(v *ExtendedVulnerability) bool {
+	_, ok := b[vulnerabilityKey(v.ChecktypeName, v.Target, v.Vulnerability)]
+	return ok
+}
+
+// compare counts the new, known and fixed vulnerabilities.
+func (b baseline) compare(vs []ExtendedVulnerability) *baselineStats {
+	stats := &baselineStats{}
+	seen := map[string]struct{}{}
+	for i := range vs {
+		v := &vs[i]
+		if v.Known {
+			seen[vulnerabilityKey(v.ChecktypeName, v.Target, v.Vulnerability)] = struct{}{}
+		}
+		if v.Excluded {
+			continue
+		}
+		if v.Known {
+			stats.Known++
+		} else {
+			stats.New++
+		}
+	}
+	stats.Fixed = len(b) - len(seen)
+	return stats
+}
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/adevinta/vulcan-agent/log"
 	report "github.com/adevinta/vulcan-report"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
 )
 
 const (
@@ -21,13 +24,20 @@ const (
 type ExtendedVulnerability struct {
 	*report.CheckData
 	*report.Vulnerability
-	Severity *Severity
-	Excluded bool
+	Severity  *Severity
+	AssetType string
+	Excluded  bool
+	Exclusion *config.Exclusion // Exclusion matching the vulnerability
+	Known     bool              // Present in the baseline
 }
 
-func summaryTable(s []ExtendedVulnerability, l log.Logger) {
-	if len(s) == 0 {
+func summaryTable(s []ExtendedVulnerability, stats *baselineStats, exclusions []config.Exclusion, l log.Logger) {
+	warnings := exclusionWarnings(s, exclusions, time.Now())
+	if len(s) == 0 && (stats == nil || stats.Fixed == 0) {
 		l.Infof("No vulnerabilities found during the last scan")
+		if warnings != "" {
+			l.Infof("%s", warnings)
+		}
 		return
 	}
 	data := make(map[string]int)
@@ -51,8 +61,122 @@ func summaryTable(s []ExtendedVulnerability, l log.Logger) {
 	if excluded > 0 {
 		fmt.Fprintf(buf, "\nNumber of excluded vulnerabilities: %d\n", excluded)
 	}
+	if stats != nil {
+		fmt.Fprint(buf, "\nCompared with the baseline:\n")
+		for _, c := range []struct {
+			name  string
+			count int
+		}{{"NEW", stats.New}, {"FIXED", stats.Fixed}, {"KNOWN", stats.Known}} {
+			fmt.Fprintf(buf, "%s%s%s%4d\n", indentate(baseIndent), formatString(c.name, 0), strings.Repeat("·", SummaryWidth-len(c.name)), c.count)
+		}
+	}
+	fmt.Fprint(buf, warnings)
 	fmt.Fprint(buf, "\n")
-	l.Infof(buf.String())
+	l.Infof("%s", buf.String())
+}
+
+// statusTable prints the final status of each check and a warning when some
+// of them didn't complete, unless the errors are ignored.
+func statusTable(checks []checkResult, onCheckError string, l log.Logger) {
+	if len(checks) == 0 {
+		return
+	}
+	buf := new(bytes.Buffer)
+	fmt.Fprint(buf, "\nStatus of the checks:\n")
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%sCHECKTYPE\tTARGET\tDURATION\tSTATUS\n", indentate(baseIndent))
+	incomplete := 0
+	for i := range checks {
+		c := &checks[i]
+		duration := "-"
+		if c.Duration > 0 {
+			duration = c.Duration.Round(time.Second).String()
+		}
+		color := 0
+		if !c.completed() {
+			incomplete++
+			color = 31
+		}
+		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\n", indentate(baseIndent), c.Checktype, c.Target, duration, formatString(c.Status, color))
+	}
+	w.Flush()
+	if incomplete > 0 && onCheckError != config.OnCheckErrorIgnore {
+		fmt.Fprintf(buf, "\n%s\n", formatString(fmt.Sprintf("WARNING: %d checks didn't complete, their vulnerabilities may be missing", incomplete), 33))
+	}
+	fmt.Fprint(buf, "\n")
+	l.Infof("%s", buf.String())
+}
+
+// exclusionWarnings returns the list of the expired exclusions and the ones
+// not matching any vulnerability. All the exclusions are matched against the
+// vulnerabilities, not only the first one applied, so the overlapping ones are
+// not reported as unused.
+func exclusionWarnings(s []ExtendedVulnerability, exclusions []config.Exclusion, now time.Time) string {
+	expired, unmatched := []string{}, []string{}
+	for i := range exclusions {
+		e := &exclusions[i]
+		if e.Expired(now) {
+			expired = append(expired, exclusionString(e))
+			continue
+		}
+		matched := false
+		for j := range s {
+			if matchExclusion(&s[j], e) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, exclusionString(e))
+		}
+	}
+	buf := new(bytes.Buffer)
+	for _, w := range []struct {
+		title string
+		items []string
+	}{
+		{"WARNING: Expired exclusions, no longer applied:", expired},
+		{"WARNING: Exclusions not matching any vulnerability:", unmatched},
+	} {
+		if len(w.items) == 0 {
+			continue
+		}
+		fmt.Fprintf(buf, "\n%s\n", formatString(w.title, 33))
+		for _, i := range w.items {
+			fmt.Fprintf(buf, "%s- %s\n", indentate(baseIndent), i)
+		}
+	}
+	return buf.String()
+}
+
+// exclusionString returns the defined fields of the exclusion.
+func exclusionString(e *config.Exclusion) string {
+	fields := []string{}
+	for _, f := range []struct {
+		name  string
+		value string
+	}{
+		{"target", e.Target.String()},
+		{"summary", e.Summary.String()},
+		{"affectedResource", e.AffectedResource.String()},
+		{"fingerprint", e.Fingerprint.String()},
+		{"checktype", e.Checktype.String()},
+		{"assetType", e.AssetType.String()},
+		{"maxSeverity", e.MaxSeverity},
+		{"owner", e.Owner},
+		{"ticket", e.Ticket},
+	} {
+		if f.value != "" {
+			fields = append(fields, fmt.Sprintf("%s=%q", f.name, f.value))
+		}
+	}
+	if !e.Expires.IsZero() {
+		fields = append(fields, fmt.Sprintf("expires=%s", e.Expires))
+	}
+	if loc := e.Locate().String(); loc != "" {
+		fields = append(fields, fmt.Sprintf("(%s)", loc))
+	}
+	return strings.Join(fields, " ")
 }
 
 func printVulnerability(v *ExtendedVulnerability, l log.Logger) string {
@@ -67,11 +191,7 @@ func printVulnerability(v *ExtendedVulnerability, l log.Logger) string {
 	n := (Width - len(severity)) / 2
 	fmt.Fprint(buf, formatString(fmt.Sprintf("\n%s%s%s\n", strings.Repeat("=", n), severity, strings.Repeat("=", Width-n-len(severity))), color))
 	fmt.Fprintf(buf, "%s %s\n", formatString("TARGET:", 0), v.Target)
-	affectedResource := v.Vulnerability.AffectedResourceString
-	if affectedResource == "" {
-		affectedResource = v.Vulnerability.AffectedResource
-	}
-	if affectedResource != "" {
+	if affectedResource := v.affectedResource(); affectedResource != "" {
 		fmt.Fprintf(buf, "%s %s\n", formatString("AFFECTED RESOURCE:", 0), affectedResource)
 	}
 	fmt.Fprintf(buf, "%s %s\n", formatString("SUMMARY:", 0), v.Vulnerability.Summary)
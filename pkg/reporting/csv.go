@@ -0,0 +1,109 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package reporting
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvColumn is a column of the csv report.
+type csvColumn struct {
+	name  string
+	value func(v *ExtendedVulnerability) string
+}
+
+// csvColumns contains the available columns in the default order.
+var csvColumns = []csvColumn{
+	{"checktype", func(v *ExtendedVulnerability) string { return v.ChecktypeName }},
+	{"target", func(v *ExtendedVulnerability) string { return v.Target }},
+	{"assetType", func(v *ExtendedVulnerability) string { return v.AssetType }},
+	{"severity", func(v *ExtendedVulnerability) string { return severityLabel(v.Severity.Name) }},
+	{"score", func(v *ExtendedVulnerability) string { return strconv.FormatFloat(float64(v.Score), 'f', -1, 32) }},
+	{"summary", func(v *ExtendedVulnerability) string { return v.Summary }},
+	{"affectedResource", func(v *ExtendedVulnerability) string { return v.affectedResource() }},
+	{"fingerprint", func(v *ExtendedVulnerability) string { return v.Fingerprint }},
+	{"cwe", func(v *ExtendedVulnerability) string {
+		if v.CWEID == 0 {
+			return ""
+		}
+		return fmt.Sprintf("CWE-%d", v.CWEID)
+	}},
+	{"references", func(v *ExtendedVulnerability) string { return strings.Join(v.References, " ") }},
+	{"excluded", func(v *ExtendedVulnerability) string { return strconv.FormatBool(v.Excluded) }},
+}
+
+// CSVColumnNames returns the names of the columns available in the csv report.
+func CSVColumnNames() []string {
+	names := []string{}
+	for _, c := range csvColumns {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+func findCSVColumn(name string) (csvColumn, bool) {
+	for _, c := range csvColumns {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return csvColumn{}, false
+}
+
+// csvEscape prefixes with a quote the values that spreadsheets would evaluate
+// as formulas, i.e. a summary like =HYPERLINK(...) taken from the scanned
+// asset.
+func csvEscape(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// writeCSV writes a row for each vulnerability over the threshold, or all of
+// them when unfiltered, including the excluded ones, with the columns in
+// reporting.csvColumns or all of them.
+func writeCSV(w io.Writer, data *reportData) error {
+	columns := csvColumns
+	if data.cfg != nil && len(data.cfg.Reporting.CSVColumns) > 0 {
+		columns = []csvColumn{}
+		for _, name := range data.cfg.Reporting.CSVColumns {
+			c, ok := findCSVColumn(name)
+			if !ok {
+				return fmt.Errorf("unknown csv column %s, allowed values %v", name, CSVColumnNames())
+			}
+			columns = append(columns, c)
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		row[i] = c.name
+	}
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+	for _, s := range severities {
+		for i := range data.vulns {
+			v := &data.vulns[i]
+			if v.Severity.Name != s.Name || (data.belowThreshold(v) && !data.unfiltered) {
+				continue
+			}
+			for j, c := range columns {
+				row[j] = csvEscape(c.value(v))
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
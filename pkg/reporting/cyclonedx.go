@@ -0,0 +1,245 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package reporting
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
+)
+
+const cyclonedxVersion = "1.4"
+
+// cyclonedxSeverities maps the severity names to CycloneDX severities.
+var cyclonedxSeverities = map[string]string{
+	"CRITICAL": "critical",
+	"HIGH":     "high",
+	"MEDIUM":   "medium",
+	"LOW":      "low",
+	"ALL":      "info",
+}
+
+// vulnIDPattern matches the identifiers of the public vulnerability databases.
+var vulnIDPattern = regexp.MustCompile(`\b(CVE-\d{4}-\d{4,}|GHSA(-[23456789cfghjmpqrvwx]{4}){3})\b`)
+
+// Subset of the CycloneDX 1.4 object model required to report the vulnerabilities.
+// See https://cyclonedx.org/docs/1.4/json/
+type cyclonedxBOM struct {
+	BOMFormat       string                   `json:"bomFormat"`
+	SpecVersion     string                   `json:"specVersion"`
+	SerialNumber    string                   `json:"serialNumber"`
+	Version         int                      `json:"version"`
+	Metadata        cyclonedxMetadata        `json:"metadata"`
+	Components      []cyclonedxComponent     `json:"components"`
+	Vulnerabilities []cyclonedxVulnerability `json:"vulnerabilities"`
+}
+
+type cyclonedxMetadata struct {
+	Timestamp string          `json:"timestamp"`
+	Tools     []cyclonedxTool `json:"tools"`
+}
+
+type cyclonedxTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type cyclonedxComponent struct {
+	BOMRef string `json:"bom-ref"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Group  string `json:"group,omitempty"`
+}
+
+type cyclonedxVulnerability struct {
+	BOMRef         string               `json:"bom-ref"`
+	ID             string               `json:"id"`
+	Source         *cyclonedxSource     `json:"source,omitempty"`
+	Ratings        []cyclonedxRating    `json:"ratings"`
+	Description    string               `json:"description,omitempty"`
+	Detail         string               `json:"detail,omitempty"`
+	Recommendation string               `json:"recommendation,omitempty"`
+	Advisories     []cyclonedxAdvisory  `json:"advisories,omitempty"`
+	Affects        []cyclonedxAffect    `json:"affects"`
+	Analysis       *cyclonedxAnalysis   `json:"analysis,omitempty"`
+	Properties     []cyclonedxProperty  `json:"properties,omitempty"`
+	References     []cyclonedxReference `json:"references,omitempty"`
+}
+
+type cyclonedxSource struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type cyclonedxRating struct {
+	Score    float32 `json:"score,omitempty"`
+	Severity string  `json:"severity"`
+	Method   string  `json:"method,omitempty"`
+}
+
+type cyclonedxAdvisory struct {
+	URL string `json:"url"`
+}
+
+type cyclonedxAffect struct {
+	Ref string `json:"ref"`
+}
+
+type cyclonedxAnalysis struct {
+	State    string   `json:"state"`
+	Response []string `json:"response,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+type cyclonedxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cyclonedxReference struct {
+	ID     string          `json:"id"`
+	Source cyclonedxSource `json:"source"`
+}
+
+// vulnerabilityIDs returns the public identifiers found in the summary and
+// the references of the vulnerability.
+func vulnerabilityIDs(v *ExtendedVulnerability) []string {
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, s := range append([]string{v.Summary}, v.References...) {
+		for _, id := range vulnIDPattern.FindAllString(s, -1) {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// vulnerabilitySource returns the database publishing the identifier.
+func vulnerabilitySource(id string) *cyclonedxSource {
+	if strings.HasPrefix(id, "GHSA") {
+		return &cyclonedxSource{Name: "GitHub", URL: "https://github.com/advisories/" + id}
+	}
+	return &cyclonedxSource{Name: "NVD", URL: "https://nvd.nist.gov/vuln/detail/" + id}
+}
+
+// exclusionAnalysis returns the VEX analysis of an excluded vulnerability. An
+// exclusion is a decision not to fix it, not a proof that the component is
+// not affected.
+func exclusionAnalysis(e *config.Exclusion) *cyclonedxAnalysis {
+	details := []string{}
+	for _, f := range []struct {
+		name  string
+		value string
+	}{{"", e.Reason}, {"owner", e.Owner}, {"ticket", e.Ticket}} {
+		switch {
+		case f.value == "":
+		case f.name == "":
+			details = append(details, f.value)
+		default:
+			details = append(details, fmt.Sprintf("%s: %s", f.name, f.value))
+		}
+	}
+	if !e.Expires.IsZero() {
+		details = append(details, fmt.Sprintf("expires: %s", e.Expires))
+	}
+	return &cyclonedxAnalysis{
+		State:    "exploitable",
+		Response: []string{"will_not_fix"},
+		Detail:   strings.Join(details, ", "),
+	}
+}
+
+// writeCycloneDX writes a CycloneDX BOM with a component for each affected
+// resource and the vulnerabilities reported over the threshold, or all of them
+// when unfiltered. The excluded vulnerabilities are included with their VEX
+// analysis.
+func writeCycloneDX(w io.Writer, data *reportData) error {
+	bom := cyclonedxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  cyclonedxVersion,
+		SerialNumber: "urn:uuid:" + uuid.New().String(),
+		Version:      1,
+		Metadata: cyclonedxMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools:     []cyclonedxTool{{Name: toolName}},
+		},
+		Components:      []cyclonedxComponent{},
+		Vulnerabilities: []cyclonedxVulnerability{},
+	}
+	if data.run != nil {
+		bom.Metadata.Tools[0].Version = data.run.Version
+	}
+
+	components := map[string]bool{}
+	for i := range data.vulns {
+		v := &data.vulns[i]
+		if data.belowThreshold(v) && !data.unfiltered {
+			continue
+		}
+
+		// The vulnerabilities without affected resource affect the target.
+		c := cyclonedxComponent{Type: "application", Name: v.Target}
+		if r := v.affectedResource(); r != "" {
+			c = cyclonedxComponent{Type: "library", Name: r, Group: v.Target}
+		}
+		c.BOMRef = fmt.Sprintf("%s|%s", c.Group, c.Name)
+		if !components[c.BOMRef] {
+			components[c.BOMRef] = true
+			bom.Components = append(bom.Components, c)
+		}
+
+		cv := cyclonedxVulnerability{
+			BOMRef:      fmt.Sprintf("vulnerability-%d", len(bom.Vulnerabilities)+1),
+			ID:          v.Summary,
+			Description: v.Description,
+			Detail:      v.Details,
+			Ratings: []cyclonedxRating{{
+				Score:    v.Score,
+				Severity: cyclonedxSeverities[v.Severity.Name],
+				// The scores of the checks are not always computed from a CVSS vector.
+				Method: "other",
+			}},
+			Affects: []cyclonedxAffect{{Ref: c.BOMRef}},
+			Properties: []cyclonedxProperty{
+				{Name: "vulcan:checktype", Value: v.ChecktypeName},
+				{Name: "vulcan:summary", Value: v.Summary},
+			},
+		}
+		if len(v.Recommendations) > 0 {
+			cv.Recommendation = strings.Join(v.Recommendations, "\n")
+		}
+		if v.Fingerprint != "" {
+			cv.Properties = append(cv.Properties, cyclonedxProperty{Name: "vulcan:fingerprint", Value: v.Fingerprint})
+		}
+		if ids := vulnerabilityIDs(v); len(ids) > 0 {
+			cv.ID, cv.Source = ids[0], vulnerabilitySource(ids[0])
+			for _, id := range ids[1:] {
+				cv.References = append(cv.References, cyclonedxReference{ID: id, Source: *vulnerabilitySource(id)})
+			}
+		}
+		for _, r := range v.References {
+			if strings.HasPrefix(r, "http://") || strings.HasPrefix(r, "https://") {
+				cv.Advisories = append(cv.Advisories, cyclonedxAdvisory{URL: r})
+			}
+		}
+		if v.Excluded && v.Exclusion != nil {
+			cv.Analysis = exclusionAnalysis(v.Exclusion)
+		}
+		bom.Vulnerabilities = append(bom.Vulnerabilities, cv)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bom)
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package reporting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/adevinta/vulcan-agent/log"
+	report "github.com/adevinta/vulcan-report"
+)
+
+// DiffEntry is a vulnerability added, removed or changed between two reports.
+type DiffEntry struct {
+	Target           string               `json:"target"`
+	Checktype        string               `json:"checktype"`
+	Severity         string               `json:"severity"`
+	PreviousSeverity string               `json:"previous_severity,omitempty"`
+	Vulnerability    report.Vulnerability `json:"vulnerability"`
+}
+
+// Diff contains the differences between two reports.
+type Diff struct {
+	Added   []DiffEntry `json:"added"`
+	Removed []DiffEntry `json:"removed"`
+	Changed []DiffEntry `json:"changed"`
+}
+
+type diffVulnerability struct {
+	target    string
+	checktype string
+	vuln      report.Vulnerability
+}
+
+// indexReports returns the vulnerabilities with the same checktype and
+// vulnerabilityKey, and the keys in order of appearance.
+func indexReports(reports []report.Report) (map[string][]diffVulnerability, []string) {
+	m := map[string][]diffVulnerability{}
+	keys := []string{}
+	for _, r := range reports {
+		for _, v := range r.Vulnerabilities {
+			key := fmt.Sprintf("%s|%s", r.ChecktypeName, vulnerabilityKey(r.ChecktypeName, r.Target, &v))
+			if _, ok := m[key]; !ok {
+				keys = append(keys, key)
+			}
+			m[key] = append(m[key], diffVulnerability{target: r.Target, checktype: r.ChecktypeName, vuln: v})
+		}
+	}
+	return m, keys
+}
+
+func newDiffEntry(d diffVulnerability) DiffEntry {
+	return DiffEntry{
+		Target:        d.target,
+		Checktype:     d.checktype,
+		Severity:      FindSeverityByScore(d.vuln.Score).Name,
+		Vulnerability: d.vuln,
+	}
+}
+
+// DiffReports compares two reports written by Generate matching the
+// vulnerabilities by checktype, target and fingerprint, or summary and
+// affected resource when they don't have fingerprint.
+func DiffReports(previous, current []report.Report) *Diff {
+	d := &Diff{
+		Added:   []DiffEntry{},
+		Removed: []DiffEntry{},
+		Changed: []DiffEntry{},
+	}
+	pm, pkeys := indexReports(previous)
+	cm, ckeys := indexReports(current)
+	// The vulnerabilities with the same key are matched in order, the
+	// remaining ones are added or removed.
+	for _, k := range ckeys {
+		for i, c := range cm[k] {
+			if i >= len(pm[k]) {
+				d.Added = append(d.Added, newDiffEntry(c))
+				continue
+			}
+			e := newDiffEntry(c)
+			if ps := FindSeverityByScore(pm[k][i].vuln.Score).Name; ps != e.Severity {
+				e.PreviousSeverity = ps
+				d.Changed = append(d.Changed, e)
+			}
+		}
+	}
+	for _, k := range pkeys {
+		for i, p := range pm[k] {
+			if i >= len(cm[k]) {
+				d.Removed = append(d.Removed, newDiffEntry(p))
+			}
+		}
+	}
+	for _, entries := range [][]DiffEntry{d.Added, d.Removed, d.Changed} {
+		sortDiffEntries(entries)
+	}
+	return d
+}
+
+// sortDiffEntries sorts the entries by severity, target and summary.
+func sortDiffEntries(entries []DiffEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Vulnerability.Score != b.Vulnerability.Score {
+			return a.Vulnerability.Score > b.Vulnerability.Score
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Vulnerability.Summary < b.Vulnerability.Summary
+	})
+}
+
+// WriteJSON writes the diff as json.
+func (d *Diff) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// Print prints the diff in the same style as the summary table.
+func (d *Diff) Print(l log.Logger) {
+	buf := new(bytes.Buffer)
+	fmt.Fprint(buf, "\nDifferences between the reports:\n")
+	for _, g := range []struct {
+		name    string
+		entries []DiffEntry
+	}{{"ADDED", d.Added}, {"REMOVED", d.Removed}, {"CHANGED", d.Changed}} {
+		fmt.Fprintf(buf, "%s%s%s%4d\n", indentate(baseIndent), formatString(g.name, 0), strings.Repeat("·", SummaryWidth-len(g.name)), len(g.entries))
+	}
+	for _, g := range []struct {
+		name    string
+		entries []DiffEntry
+	}{{"ADDED VULNERABILITIES:", d.Added}, {"REMOVED VULNERABILITIES:", d.Removed}, {"CHANGED SEVERITIES:", d.Changed}} {
+		if len(g.entries) == 0 {
+			continue
+		}
+		fmt.Fprintf(buf, "\n%s\n", formatString(g.name, 0))
+		for _, e := range g.entries {
+			fmt.Fprintf(buf, "%s%s %s\n", indentate(baseIndent), diffSeverity(e), e.Vulnerability.Summary)
+			fmt.Fprintf(buf, "%s%s %s\n", indentate(2*baseIndent), formatString("TARGET:", 0), e.Target)
+			fmt.Fprintf(buf, "%s%s %s\n", indentate(2*baseIndent), formatString("CHECKTYPE:", 0), e.Checktype)
+			affectedResource := e.Vulnerability.AffectedResourceString
+			if affectedResource == "" {
+				affectedResource = e.Vulnerability.AffectedResource
+			}
+			if affectedResource != "" {
+				fmt.Fprintf(buf, "%s%s %s\n", indentate(2*baseIndent), formatString("AFFECTED RESOURCE:", 0), affectedResource)
+			}
+		}
+	}
+	fmt.Fprint(buf, "\n")
+	l.Infof("%s", buf.String())
+}
+
+// diffSeverity returns the colored severity of the entry including the
+// previous one when it has changed.
+func diffSeverity(e DiffEntry) string {
+	s, _ := FindSeverity(e.Severity)
+	current := formatString(fmt.Sprintf("[%s]", severityLabel(e.Severity)), s.Color)
+	if e.PreviousSeverity == "" {
+		return current
+	}
+	p, _ := FindSeverity(e.PreviousSeverity)
+	return fmt.Sprintf("%s -> %s", formatString(fmt.Sprintf("[%s]", severityLabel(e.PreviousSeverity)), p.Color), current)
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package reporting
+
+import (
+	"html/template"
+	"io"
+	"sort"
+	"time"
+
+	report "github.com/adevinta/vulcan-report"
+)
+
+// htmlColors maps the severity names to the colors used in the html report.
+var htmlColors = map[string]string{
+	"CRITICAL": "#8e44ad",
+	"HIGH":     "#c0392b",
+	"MEDIUM":   "#d68910",
+	"LOW":      "#2e86c1",
+	"ALL":      "#7f8c8d",
+}
+
+type htmlSeverity struct {
+	Name    string
+	Label   string
+	Color   string
+	Count   int
+	Checked bool
+}
+
+type htmlChecktype struct {
+	Name  string
+	Vulns []*ExtendedVulnerability
+}
+
+type htmlTarget struct {
+	Name       string
+	Checktypes []*htmlChecktype
+}
+
+type htmlReport struct {
+	Generated  string
+	Threshold  string
+	Severities []htmlSeverity
+	Excluded   int
+	Targets    []*htmlTarget
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"severityLabel": severityLabel,
+	"severityColor": func(name string) string { return htmlColors[name] },
+	"columns":       resourceColumns,
+}).Parse(htmlLayout))
+
+// resourceColumns returns the header of the resources group or the keys of
+// its rows when the header is not defined.
+func resourceColumns(r report.ResourcesGroup) []string {
+	if len(r.Header) != 0 {
+		return r.Header
+	}
+	keys := map[string]struct{}{}
+	columns := []string{}
+	for _, row := range r.Rows {
+		for k := range row {
+			if _, ok := keys[k]; !ok {
+				keys[k] = struct{}{}
+				columns = append(columns, k)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// severityLabel returns the name used to display the severity.
+func severityLabel(name string) string {
+	if name == "ALL" {
+		return "INFORMATIONAL"
+	}
+	return name
+}
+
+// writeHTML writes a self-contained html report with all the vulnerabilities
+// grouped by target, checktype and severity. The excluded vulnerabilities and
+// the ones below the threshold are included but hidden by default.
+func writeHTML(w io.Writer, data *reportData) error {
+	r := htmlReport{
+		Generated: time.Now().Format(time.RFC3339),
+		Threshold: data.threshold.Name,
+	}
+	counts := map[string]int{}
+	targets := map[string]*htmlTarget{}
+	checktypes := map[string]*htmlChecktype{}
+	for _, s := range severities {
+		for i := range data.vulns {
+			v := &data.vulns[i]
+			if v.Severity.Name != s.Name {
+				continue
+			}
+			if v.Excluded {
+				r.Excluded++
+			} else {
+				counts[v.Severity.Name]++
+			}
+			t, ok := targets[v.Target]
+			if !ok {
+				t = &htmlTarget{Name: v.Target}
+				targets[v.Target] = t
+				r.Targets = append(r.Targets, t)
+			}
+			key := v.Target + "\x00" + v.ChecktypeName
+			ct, ok := checktypes[key]
+			if !ok {
+				ct = &htmlChecktype{Name: v.ChecktypeName}
+				checktypes[key] = ct
+				t.Checktypes = append(t.Checktypes, ct)
+			}
+			ct.Vulns = append(ct.Vulns, v)
+		}
+	}
+	sort.Slice(r.Targets, func(i, j int) bool {
+		return r.Targets[i].Name < r.Targets[j].Name
+	})
+	for _, t := range r.Targets {
+		sort.Slice(t.Checktypes, func(i, j int) bool {
+			return t.Checktypes[i].Name < t.Checktypes[j].Name
+		})
+	}
+	for _, s := range severities {
+		r.Severities = append(r.Severities, htmlSeverity{
+			Name:    s.Name,
+			Label:   severityLabel(s.Name),
+			Color:   htmlColors[s.Name],
+			Count:   counts[s.Name],
+			Checked: s.Threshold >= data.threshold.Threshold,
+		})
+	}
+	return htmlTemplate.Execute(w, r)
+}
+
+const htmlLayout = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>vulcan-local report</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 0; color: #222; background: #f4f6f8; }
+header { background: #1f2d3d; color: #fff; padding: 16px 32px; }
+header h1 { margin: 0 0 4px 0; font-size: 22px; }
+header p { margin: 0; font-size: 13px; color: #c8d1da; }
+main { padding: 16px 32px; }
+.summary { display: flex; gap: 12px; flex-wrap: wrap; margin-bottom: 16px; }
+.summary div { background: #fff; border-radius: 4px; padding: 8px 16px; border-left: 6px solid; min-width: 120px; }
+.summary span { display: block; font-size: 24px; font-weight: bold; }
+.filters { background: #fff; border-radius: 4px; padding: 8px 16px; margin-bottom: 16px; }
+.filters label { margin-right: 16px; cursor: pointer; }
+section.target { margin-bottom: 24px; }
+section.target > h2 { font-size: 18px; border-bottom: 2px solid #1f2d3d; padding-bottom: 4px; word-break: break-all; }
+section.checktype > h3 { font-size: 15px; color: #444; }
+details.vuln { background: #fff; border-radius: 4px; margin: 6px 0; border-left: 6px solid; }
+details.vuln > summary { padding: 8px 12px; cursor: pointer; }
+details.vuln .body { padding: 0 16px 12px 16px; font-size: 14px; }
+.badge { display: inline-block; color: #fff; border-radius: 3px; padding: 1px 6px; font-size: 12px; font-weight: bold; margin-right: 8px; }
+.excluded-badge { background: #95a5a6; }
+.resource { color: #555; font-family: monospace; margin-left: 8px; }
+.field { font-weight: bold; margin-top: 10px; }
+pre { white-space: pre-wrap; word-break: break-word; background: #f4f6f8; padding: 8px; border-radius: 4px; }
+table { border-collapse: collapse; margin-top: 4px; width: 100%; font-size: 13px; }
+th, td { border: 1px solid #d5dbe1; padding: 4px 8px; text-align: left; vertical-align: top; word-break: break-word; }
+th { background: #eaeef2; }
+.hidden { display: none; }
+</style>
+</head>
+<body>
+<header>
+<h1>vulcan-local report</h1>
+<p>Generated {{ .Generated }} &middot; Threshold {{ .Threshold }}</p>
+</header>
+<main>
+<div class="summary">
+{{- range .Severities }}
+<div style="border-color: {{ .Color }}">{{ .Label }}<span>{{ .Count }}</span></div>
+{{- end }}
+<div style="border-color: #95a5a6">EXCLUDED<span>{{ .Excluded }}</span></div>
+</div>
+<div class="filters">
+<strong>Show:</strong>
+{{- range .Severities }}
+<label><input type="checkbox" class="severity-filter" value="{{ .Name }}"{{ if .Checked }} checked{{ end }}> {{ .Label }}</label>
+{{- end }}
+<label><input type="checkbox" id="excluded-filter"> Excluded</label>
+</div>
+{{- range .Targets }}
+<section class="target">
+<h2>{{ .Name }}</h2>
+{{- range .Checktypes }}
+<section class="checktype">
+<h3>{{ .Name }}</h3>
+{{- range .Vulns }}
+<details class="vuln" data-severity="{{ .Severity.Name }}" data-excluded="{{ .Excluded }}" style="border-color: {{ severityColor .Severity.Name }}">
+<summary><span class="badge" style="background: {{ severityColor .Severity.Name }}">{{ severityLabel .Severity.Name }}</span>{{ if .Excluded }}<span class="badge excluded-badge">EXCLUDED</span>{{ end }}{{ .Summary }}{{ with .AffectedResourceString }}<span class="resource">{{ . }}</span>{{ else }}{{ with .AffectedResource }}<span class="resource">{{ . }}</span>{{ end }}{{ end }}</summary>
+<div class="body">
+<div class="field">Score</div>{{ printf "%.1f" .Score }}
+{{- with .Fingerprint }}<div class="field">Fingerprint</div><code>{{ . }}</code>{{ end }}
+{{- with .Description }}<div class="field">Description</div><pre>{{ . }}</pre>{{ end }}
+{{- with .Details }}<div class="field">Details</div><pre>{{ . }}</pre>{{ end }}
+{{- with .ImpactDetails }}<div class="field">Impact</div><pre>{{ . }}</pre>{{ end }}
+{{- with .Recommendations }}<div class="field">Recommendations</div><ul>{{ range . }}<li>{{ . }}</li>{{ end }}</ul>{{ end }}
+{{- with .References }}<div class="field">References</div><ul>{{ range . }}{{ if . }}<li><a href="{{ . }}">{{ . }}</a></li>{{ end }}{{ end }}</ul>{{ end }}
+{{- range .Resources }}{{ if .Rows }}
+<div class="field">{{ .Name }}</div>
+<table>
+{{- $header := columns . }}
+<tr>{{ range $header }}<th>{{ . }}</th>{{ end }}</tr>
+{{- range .Rows }}{{ $row := . }}
+<tr>{{ range $header }}<td>{{ index $row . }}</td>{{ end }}</tr>
+{{- end }}
+</table>
+{{- end }}{{ end }}
+</div>
+</details>
+{{- end }}
+</section>
+{{- end }}
+</section>
+{{- end }}
+</main>
+<script>
+(function() {
+  function applyFilters() {
+    var shown = {};
+    document.querySelectorAll(".severity-filter").forEach(function(f) { shown[f.value] = f.checked; });
+    var excluded = document.getElementById("excluded-filter").checked;
+    document.querySelectorAll("details.vuln").forEach(function(v) {
+      var visible = shown[v.dataset.severity] && (excluded || v.dataset.excluded !== "true");
+      v.classList.toggle("hidden", !visible);
+    });
+    document.querySelectorAll("section.checktype, section.target").forEach(function(s) {
+      s.classList.toggle("hidden", s.querySelector("details.vuln:not(.hidden)") === null);
+    });
+  }
+  document.querySelectorAll(".filters input").forEach(function(f) { f.addEventListener("change", applyFilters); });
+  applyFilters();
+})();
+</script>
+</body>
+</html>
+`
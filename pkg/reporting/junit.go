@@ -0,0 +1,174 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package reporting
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/adevinta/vulcan-agent/stateupdater"
+	report "github.com/adevinta/vulcan-report"
+)
+
+// Subset of the JUnit XML format understood by the most common CI systems.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+	start     time.Time
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes a JUnit XML report with a testsuite for each target and a
+// testcase for each check. The checks with vulnerabilities over the threshold
+// are reported as failures, the checks not finished as errors and the ones
+// with only excluded vulnerabilities as skipped.
+func writeJUnit(w io.Writer, data *reportData) error {
+	checkVulns := map[string][]*ExtendedVulnerability{}
+	for i := range data.vulns {
+		v := &data.vulns[i]
+		checkVulns[v.CheckID] = append(checkVulns[v.CheckID], v)
+	}
+
+	suites := map[string]*junitTestSuite{}
+	for _, r := range data.reports {
+		target := originalTarget(data.cfg, r)
+		suite, ok := suites[target]
+		if !ok {
+			suite = &junitTestSuite{Name: target}
+			suites[target] = suite
+		}
+		tc := newJUnitTestCase(r, target, checkVulns[r.CheckID], data.threshold)
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+		suite.Time += tc.Time
+		switch {
+		case tc.Failure != nil:
+			suite.Failures++
+		case tc.Error != nil:
+			suite.Errors++
+		case tc.Skipped != nil:
+			suite.Skipped++
+		}
+		if !r.StartTime.IsZero() && (suite.start.IsZero() || r.StartTime.Before(suite.start)) {
+			suite.start = r.StartTime
+			suite.Timestamp = r.StartTime.Format(time.RFC3339)
+		}
+	}
+
+	all := junitTestSuites{Name: toolName}
+	for _, suite := range suites {
+		sort.Slice(suite.Cases, func(i, j int) bool {
+			return suite.Cases[i].Name < suite.Cases[j].Name
+		})
+		all.Suites = append(all.Suites, *suite)
+		all.Tests += suite.Tests
+		all.Failures += suite.Failures
+		all.Errors += suite.Errors
+		all.Skipped += suite.Skipped
+		all.Time += suite.Time
+	}
+	sort.Slice(all.Suites, func(i, j int) bool {
+		return all.Suites[i].Name < all.Suites[j].Name
+	})
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(all); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func newJUnitTestCase(r *report.Report, target string, vulns []*ExtendedVulnerability, threshold *Severity) junitTestCase {
+	tc := junitTestCase{
+		Name:      r.ChecktypeName,
+		Classname: target,
+	}
+	if !r.StartTime.IsZero() && r.EndTime.After(r.StartTime) {
+		tc.Time = r.EndTime.Sub(r.StartTime).Seconds()
+	}
+
+	failed := new(bytes.Buffer)
+	nFailed, nExcluded := 0, 0
+	var maxSeverity *Severity
+	for _, v := range vulns {
+		switch {
+		case v.Excluded:
+			nExcluded++
+		case v.isReported(threshold):
+			nFailed++
+			if maxSeverity == nil || v.Severity.Threshold > maxSeverity.Threshold {
+				maxSeverity = v.Severity
+			}
+			fmt.Fprintf(failed, "[%s] %s\n", v.Severity.Name, v.Summary)
+			if affectedResource := v.affectedResource(); affectedResource != "" {
+				fmt.Fprintf(failed, "  %s\n", affectedResource)
+			}
+		}
+	}
+
+	switch {
+	case nFailed > 0:
+		tc.Failure = &junitMessage{
+			Message: fmt.Sprintf("%d vulnerabilities with severity %s or higher", nFailed, threshold.Name),
+			Type:    maxSeverity.Name,
+			Text:    failed.String(),
+		}
+	case r.Status != stateupdater.StatusFinished:
+		tc.Error = &junitMessage{
+			Message: fmt.Sprintf("check finished with status %s", r.Status),
+			Type:    r.Status,
+			Text:    r.Error,
+		}
+	case nExcluded > 0:
+		tc.Skipped = &junitMessage{
+			Message: fmt.Sprintf("%d excluded vulnerabilities", nExcluded),
+		}
+	}
+	if tc.Failure != nil || tc.Error != nil {
+		tc.SystemOut = fmt.Sprintf("check_id=%s status=%s options=%s", r.CheckID, r.Status, r.Options)
+	}
+	return tc
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package reporting
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultMarkdownMaxSize is the default size limit in bytes of the markdown
+// report, below the 65536 characters allowed in a GitHub comment.
+const DefaultMarkdownMaxSize = 65000
+
+// writeMarkdown writes a summary table and a collapsible section for each
+// reported vulnerability. The sections that don't fit in the size limit are
+// left out with a note.
+func writeMarkdown(w io.Writer, data *reportData) error {
+	maxSize := DefaultMarkdownMaxSize
+	if data.cfg != nil && data.cfg.Reporting.MarkdownMaxSize > 0 {
+		maxSize = data.cfg.Reporting.MarkdownMaxSize
+	}
+
+	vulns := []*ExtendedVulnerability{}
+	for _, s := range severities {
+		for i := range data.vulns {
+			v := &data.vulns[i]
+			if v.Severity.Name == s.Name && v.isReported(data.threshold) && !v.Known {
+				vulns = append(vulns, v)
+			}
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "## %s results\n\n", toolName)
+	markdownSummary(buf, data, vulns)
+	if len(vulns) > 0 {
+		fmt.Fprint(buf, "\n### Vulnerabilities\n")
+	}
+	for i, v := range vulns {
+		section := markdownVulnerability(v)
+		note := fmt.Sprintf("\n> **Note:** The report was truncated, %d vulnerabilities are not shown.\n", len(vulns)-i)
+		if buf.Len()+len(section)+len(note) > maxSize {
+			fmt.Fprint(buf, note)
+			break
+		}
+		fmt.Fprint(buf, section)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// markdownSummary writes the number of vulnerabilities by severity, the
+// excluded ones and the checks that didn't complete.
+func markdownSummary(buf *bytes.Buffer, data *reportData, vulns []*ExtendedVulnerability) {
+	counts := map[string]int{}
+	for _, v := range vulns {
+		counts[v.Severity.Name]++
+	}
+	fmt.Fprint(buf, "| Severity | Vulnerabilities |\n| --- | ---: |\n")
+	for _, s := range severities {
+		if s.Threshold >= data.threshold.Threshold {
+			fmt.Fprintf(buf, "| %s | %d |\n", severityLabel(s.Name), counts[s.Name])
+		}
+	}
+	excluded := 0
+	for i := range data.vulns {
+		if data.vulns[i].Excluded {
+			excluded++
+		}
+	}
+	if excluded > 0 {
+		fmt.Fprintf(buf, "\nExcluded vulnerabilities: %d\n", excluded)
+	}
+	if data.cfg != nil {
+		incomplete := []string{}
+		for _, c := range checkResults(data.cfg, data.reports) {
+			if !c.completed() {
+				incomplete = append(incomplete, fmt.Sprintf("- `%s` on `%s`: %s", c.Checktype, c.Target, c.Status))
+			}
+		}
+		if len(incomplete) > 0 {
+			fmt.Fprintf(buf, "\n**Checks not completed**, their vulnerabilities may be missing:\n\n%s\n", strings.Join(incomplete, "\n"))
+		}
+	}
+}
+
+// markdownVulnerability returns the collapsible section of the vulnerability.
+func markdownVulnerability(v *ExtendedVulnerability) string {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "\n<details>\n<summary><b>%s</b> %s (%s)</summary>\n\n",
+		severityLabel(v.Severity.Name), markdownEscape(v.Summary), markdownEscape(v.ChecktypeName))
+	fmt.Fprintf(buf, "**Target:** `%s`\n\n", v.Target)
+	if r := v.affectedResource(); r != "" {
+		fmt.Fprintf(buf, "**Affected resource:** `%s`\n\n", r)
+	}
+	if v.Description != "" {
+		fmt.Fprintf(buf, "%s\n\n", v.Description)
+	}
+	if v.Details != "" {
+		fmt.Fprintf(buf, "**Details:**\n\n```\n%s\n```\n\n", strings.TrimRight(v.Details, "\n"))
+	}
+	if len(v.References) != 0 && v.References[0] != "" {
+		fmt.Fprint(buf, "**References:**\n\n")
+		for _, r := range v.References {
+			fmt.Fprintf(buf, "- %s\n", r)
+		}
+		fmt.Fprint(buf, "\n")
+	}
+	for _, r := range v.Resources {
+		if len(r.Rows) == 0 {
+			continue
+		}
+		columns := resourceColumns(r)
+		fmt.Fprintf(buf, "**%s:**\n\n| %s |\n|%s\n", markdownEscape(r.Name), strings.Join(columns, " | "), strings.Repeat(" --- |", len(columns)))
+		for _, row := range r.Rows {
+			cells := []string{}
+			for _, c := range columns {
+				cells = append(cells, markdownCell(row[c]))
+			}
+			fmt.Fprintf(buf, "| %s |\n", strings.Join(cells, " | "))
+		}
+		fmt.Fprint(buf, "\n")
+	}
+	fmt.Fprint(buf, "</details>\n")
+	return buf.String()
+}
+
+var markdownReplacer = strings.NewReplacer("<", "&lt;", ">", "&gt;", "|", "\\|")
+
+// markdownEscape escapes the text to be rendered inside html and tables.
+func markdownEscape(s string) string {
+	return markdownReplacer.Replace(s)
+}
+
+// markdownCell returns the value as a single line table cell.
+func markdownCell(s string) string {
+	return strings.ReplaceAll(markdownEscape(strings.TrimSpace(s)), "\n", "<br>")
+}
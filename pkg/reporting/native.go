@@ -0,0 +1,216 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package reporting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	report "github.com/adevinta/vulcan-report"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
+)
+
+// NativeSchemaVersion is the version of the vulcan-local report format,
+// increased on incompatible changes.
+const NativeSchemaVersion = "1"
+
+// RunInfo contains the metadata of a scan.
+type RunInfo struct {
+	ID         string    `json:"id"`
+	Version    string    `json:"version"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	ConfigHash string    `json:"config_hash,omitempty"`
+}
+
+// NativeReport is the vulcan-local report format.
+type NativeReport struct {
+	SchemaVersion   string                `json:"schema_version"`
+	Tool            string                `json:"tool"`
+	Run             RunInfo               `json:"run"`
+	Threshold       string                `json:"threshold"`
+	Checks          []NativeCheck         `json:"checks"`
+	Vulnerabilities []NativeVulnerability `json:"vulnerabilities"`
+}
+
+// NativeCheck is a check executed in the scan.
+type NativeCheck struct {
+	report.CheckData
+	ChecktypeRef   string                 `json:"checktype_ref,omitempty"`
+	OriginalTarget string                 `json:"original_target"`
+	AssetType      string                 `json:"asset_type,omitempty"`
+	CheckOptions   map[string]interface{} `json:"check_options,omitempty"`
+}
+
+// NativeVulnerability is a vulnerability found by a check.
+type NativeVulnerability struct {
+	report.Vulnerability
+	CheckID        string           `json:"check_id"`
+	Checktype      string           `json:"checktype"`
+	Target         string           `json:"target"`
+	AssetType      string           `json:"asset_type,omitempty"`
+	Severity       string           `json:"severity"`
+	Excluded       bool             `json:"excluded"`
+	Exclusion      *NativeExclusion `json:"exclusion,omitempty"`
+	BelowThreshold bool             `json:"below_threshold"`
+}
+
+// NativeExclusion is the exclusion matching a vulnerability.
+type NativeExclusion struct {
+	Target           string `json:"target,omitempty"`
+	Summary          string `json:"summary,omitempty"`
+	AffectedResource string `json:"affected_resource,omitempty"`
+	Fingerprint      string `json:"fingerprint,omitempty"`
+	Checktype        string `json:"checktype,omitempty"`
+	AssetType        string `json:"asset_type,omitempty"`
+	MaxSeverity      string `json:"max_severity,omitempty"`
+	Reason           string `json:"reason,omitempty"`
+	Owner            string `json:"owner,omitempty"`
+	Ticket           string `json:"ticket,omitempty"`
+	Expires          string `json:"expires,omitempty"`
+	Location         string `json:"location,omitempty"`
+}
+
+func nativeExclusion(e *config.Exclusion) *NativeExclusion {
+	n := &NativeExclusion{
+		Target:           e.Target.String(),
+		Summary:          e.Summary.String(),
+		AffectedResource: e.AffectedResource.String(),
+		Fingerprint:      e.Fingerprint.String(),
+		Checktype:        e.Checktype.String(),
+		AssetType:        e.AssetType.String(),
+		MaxSeverity:      e.MaxSeverity,
+		Reason:           e.Reason,
+		Owner:            e.Owner,
+		Ticket:           e.Ticket,
+		Location:         e.Locate().String(),
+	}
+	if !e.Expires.IsZero() {
+		n.Expires = e.Expires.String()
+	}
+	return n
+}
+
+// nativeReport builds the vulcan-local report with all the vulnerabilities,
+// annotated when excluded or below the threshold, so a saved run can be
+// rendered again with other reporting settings.
+func nativeReport(data *reportData) *NativeReport {
+	r := &NativeReport{
+		SchemaVersion:   NativeSchemaVersion,
+		Tool:            toolName,
+		Threshold:       data.threshold.Name,
+		Checks:          []NativeCheck{},
+		Vulnerabilities: []NativeVulnerability{},
+	}
+	if data.run != nil {
+		r.Run = *data.run
+	}
+
+	for _, rep := range data.reports {
+		c := NativeCheck{
+			CheckData:      rep.CheckData,
+			OriginalTarget: rep.Target,
+		}
+		if cc := config.GetCheckById(data.cfg, rep.CheckID); cc != nil {
+			c.ChecktypeRef = string(cc.Type)
+			c.OriginalTarget = cc.Target
+			c.AssetType = cc.AssetType
+			c.CheckOptions = cc.Options
+		}
+		r.Checks = append(r.Checks, c)
+	}
+	sort.Slice(r.Checks, func(i, j int) bool {
+		if r.Checks[i].OriginalTarget != r.Checks[j].OriginalTarget {
+			return r.Checks[i].OriginalTarget < r.Checks[j].OriginalTarget
+		}
+		return r.Checks[i].ChecktypeName < r.Checks[j].ChecktypeName
+	})
+
+	for i := range data.vulns {
+		v := &data.vulns[i]
+		nv := NativeVulnerability{
+			Vulnerability:  *v.Vulnerability,
+			CheckID:        v.CheckID,
+			Checktype:      v.ChecktypeName,
+			Target:         v.Target,
+			AssetType:      v.AssetType,
+			Severity:       v.Severity.Name,
+			Excluded:       v.Excluded,
+			BelowThreshold: data.belowThreshold(v),
+		}
+		if v.Exclusion != nil {
+			nv.Exclusion = nativeExclusion(v.Exclusion)
+		}
+		r.Vulnerabilities = append(r.Vulnerabilities, nv)
+	}
+	return r
+}
+
+// SavedRun contains a scan read from a report file.
+type SavedRun struct {
+	Reports []report.Report
+	Checks  []config.Check // Only in the vulcan-local format
+	Run     *RunInfo       // Only in the vulcan-local format
+}
+
+// ReadSavedRun reads a report written in the json or the vulcan-local format.
+// The targets of the reports are the original ones of the checks.
+func ReadSavedRun(path string) (*SavedRun, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(content); len(trimmed) == 0 || trimmed[0] != '{' {
+		reports := []report.Report{}
+		if err := json.Unmarshal(content, &reports); err != nil {
+			return nil, fmt.Errorf("unable to parse report %s %+v", path, err)
+		}
+		return &SavedRun{Reports: reports}, nil
+	}
+
+	n := NativeReport{}
+	if err := json.Unmarshal(content, &n); err != nil {
+		return nil, fmt.Errorf("unable to parse report %s %+v", path, err)
+	}
+	if n.Tool != toolName || n.SchemaVersion != NativeSchemaVersion {
+		return nil, fmt.Errorf("unsupported report %s tool=%s schema_version=%s", path, n.Tool, n.SchemaVersion)
+	}
+	saved := &SavedRun{Run: &n.Run}
+	indexes := map[string]int{}
+	for _, c := range n.Checks {
+		indexes[c.CheckID] = len(saved.Reports)
+		r := report.Report{CheckData: c.CheckData}
+		r.Target = c.OriginalTarget
+		saved.Reports = append(saved.Reports, r)
+		saved.Checks = append(saved.Checks, config.Check{
+			Id:        c.CheckID,
+			Type:      config.ChecktypeRef(c.ChecktypeRef),
+			Target:    c.OriginalTarget,
+			NewTarget: c.OriginalTarget,
+			AssetType: c.AssetType,
+			Options:   c.CheckOptions,
+		})
+	}
+	for _, v := range n.Vulnerabilities {
+		i, ok := indexes[v.CheckID]
+		if !ok {
+			return nil, fmt.Errorf("unable to parse report %s vulnerability of unknown check %s", path, v.CheckID)
+		}
+		saved.Reports[i].Vulnerabilities = append(saved.Reports[i].Vulnerabilities, v.Vulnerability)
+	}
+	return saved, nil
+}
+
+// writeNative writes the report in the vulcan-local format.
+func writeNative(w io.Writer, data *reportData) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(nativeReport(data))
+}
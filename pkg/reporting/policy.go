@@ -0,0 +1,215 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package reporting
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adevinta/vulcan-agent/log"
+	"github.com/adevinta/vulcan-agent/stateupdater"
+	report "github.com/adevinta/vulcan-report"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/generator"
+)
+
+// CheckFailedExitCode is the exit code when checks didn't complete.
+const CheckFailedExitCode = 105
+
+// policyResult is the result of evaluating a policy rule.
+type policyResult struct {
+	rule      *config.PolicyRule
+	count     int
+	triggered bool
+	exit      int
+}
+
+// matchVulnerability returns true if the vulnerability rule matches the vulnerability.
+func matchVulnerability(r *config.PolicyRule, v *ExtendedVulnerability) bool {
+	if len(r.CheckStatus) > 0 {
+		return false
+	}
+	if r.MinSeverity != "" {
+		if s, err := FindSeverity(r.MinSeverity); err != nil || v.Severity.Threshold < s.Threshold {
+			return false
+		}
+	}
+	if r.MaxSeverity != "" {
+		if s, err := FindSeverity(r.MaxSeverity); err != nil || v.Severity.Threshold > s.Threshold {
+			return false
+		}
+	}
+	return r.Checktype.Match(v.ChecktypeName) && r.Target.Match(v.Target)
+}
+
+// checkResult is the final status of a check.
+type checkResult struct {
+	Checktype string
+	Target    string
+	Status    string
+	Duration  time.Duration
+}
+
+// completed returns true if the check finished.
+func (c *checkResult) completed() bool {
+	return c.Status == stateupdater.StatusFinished
+}
+
+// checkResults returns the status of the checks in the reports sorted by
+// target and checktype.
+func checkResults(cfg *config.Config, reports map[string]*report.Report) []checkResult {
+	results := []checkResult{}
+	for _, r := range reports {
+		c := checkResult{
+			Checktype: r.ChecktypeName,
+			Target:    originalTarget(cfg, r),
+			Status:    r.Status,
+		}
+		if !r.StartTime.IsZero() && r.EndTime.After(r.StartTime) {
+			c.Duration = r.EndTime.Sub(r.StartTime)
+		}
+		results = append(results, c)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Target != results[j].Target {
+			return results[i].Target < results[j].Target
+		}
+		return results[i].Checktype < results[j].Checktype
+	})
+	return results
+}
+
+// matchCheck returns true if the check status rule matches the check.
+func matchCheck(r *config.PolicyRule, c *checkResult) bool {
+	return generator.StringInSlice(c.Status, r.CheckStatus) &&
+		r.Checktype.Match(c.Checktype) && r.Target.Match(c.Target)
+}
+
+// evaluatePolicy applies the rules in order, each vulnerability or check is
+// counted by the first rule matching it. Returns the results of the rules and
+// the highest exit code of the fail rules triggered.
+func evaluatePolicy(rules []config.PolicyRule, vs []ExtendedVulnerability, checks []checkResult) ([]policyResult, int) {
+	results := make([]policyResult, len(rules))
+	maxSeverity := make([]*Severity, len(rules))
+	for i := range rules {
+		results[i].rule = &rules[i]
+	}
+	for i := range vs {
+		v := &vs[i]
+		// Excluded and known vulnerabilities don't break the build.
+		if v.Excluded || v.Known {
+			continue
+		}
+		for j := range rules {
+			if matchVulnerability(&rules[j], v) {
+				results[j].count++
+				if maxSeverity[j] == nil || v.Severity.Threshold > maxSeverity[j].Threshold {
+					maxSeverity[j] = v.Severity
+				}
+				break
+			}
+		}
+	}
+	for i := range checks {
+		for j := range rules {
+			if matchCheck(&rules[j], &checks[i]) {
+				results[j].count++
+				break
+			}
+		}
+	}
+
+	exit := SuccessExitCode
+	for i := range results {
+		r := &results[i]
+		if r.rule.Action == config.PolicyIgnore || r.count <= r.rule.MaxCount {
+			continue
+		}
+		r.triggered = true
+		switch {
+		case r.rule.ExitCode != 0:
+			r.exit = r.rule.ExitCode
+		case len(r.rule.CheckStatus) > 0:
+			r.exit = CheckFailedExitCode
+		default:
+			// At least the exit code of LOW, as the one of ALL is a success.
+			low, _ := FindSeverity("LOW")
+			r.exit = low.Exit
+			if maxSeverity[i] != nil && maxSeverity[i].Exit > r.exit {
+				r.exit = maxSeverity[i].Exit
+			}
+		}
+		if r.exit > exit {
+			exit = r.exit
+		}
+	}
+	return results, exit
+}
+
+// policyRuleString returns the name of the rule or its conditions.
+func policyRuleString(r *config.PolicyRule) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	fields := []string{}
+	for _, f := range []struct {
+		name  string
+		value string
+	}{
+		{"minSeverity", r.MinSeverity},
+		{"maxSeverity", r.MaxSeverity},
+		{"checktype", r.Checktype.String()},
+		{"target", r.Target.String()},
+		{"checkStatus", strings.Join(r.CheckStatus, ",")},
+	} {
+		if f.value != "" {
+			fields = append(fields, fmt.Sprintf("%s=%s", f.name, f.value))
+		}
+	}
+	if len(fields) == 0 {
+		return "all"
+	}
+	return strings.Join(fields, " ")
+}
+
+// printPolicy prints the result of each rule and which one decided the exit code.
+func printPolicy(results []policyResult, exit int, l log.Logger) {
+	buf := new(bytes.Buffer)
+	fmt.Fprint(buf, "\nPolicy evaluation:\n")
+	decided := false
+	for i, r := range results {
+		unit := "vulnerabilities"
+		if len(r.rule.CheckStatus) > 0 {
+			unit = "checks"
+		}
+		var status string
+		color := 0
+		switch {
+		case r.rule.Action == config.PolicyIgnore:
+			status = "IGNORE"
+		case r.triggered:
+			status, color = "FAIL", 31
+		default:
+			status = "PASS"
+		}
+		fmt.Fprintf(buf, "%s%s rule %d (%s): %d %s", indentate(baseIndent), formatString(fmt.Sprintf("[%s]", status), color), i+1, policyRuleString(r.rule), r.count, unit)
+		if r.rule.Action != config.PolicyIgnore {
+			fmt.Fprintf(buf, ", %d allowed", r.rule.MaxCount)
+		}
+		if r.triggered {
+			fmt.Fprintf(buf, ", exit code %d", r.exit)
+			if !decided && r.exit == exit {
+				fmt.Fprint(buf, " <- exit code of the scan")
+				decided = true
+			}
+		}
+		fmt.Fprint(buf, "\n")
+	}
+	fmt.Fprint(buf, "\n")
+	l.Infof("%s", buf.String())
+}
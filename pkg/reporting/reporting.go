@@ -7,13 +7,15 @@ package reporting
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/adevinta/vulcan-agent/log"
 	report "github.com/adevinta/vulcan-report"
 	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
-	"github.mpi-internal.com/spt-security/vulcan-local/pkg/results"
 )
 
 type Severity struct {
@@ -61,16 +63,33 @@ var severities = []Severity{
 	},
 }
 
-func isExcluded(v *ExtendedVulnerability, ex *[]config.Exclusion) bool {
-	for _, e := range *ex {
-		if strings.Contains(v.Target, e.Target) &&
-			strings.Contains(v.Summary, e.Summary) &&
-			strings.Contains(v.Fingerprint, e.Fingerprint) &&
-			(strings.Contains(v.AffectedResource, e.AffectedResource) || strings.Contains(v.AffectedResourceString, e.AffectedResource)) {
-			return true
+// findExclusion returns the first exclusion matching the vulnerability,
+// ignoring the ones expired at the given time.
+func findExclusion(v *ExtendedVulnerability, ex []config.Exclusion, now time.Time) *config.Exclusion {
+	for i := range ex {
+		e := &ex[i]
+		if !e.Expired(now) && matchExclusion(v, e) {
+			return e
 		}
 	}
-	return false
+	return nil
+}
+
+// matchExclusion returns true if the exclusion matches the vulnerability,
+// regardless of its expiration.
+func matchExclusion(v *ExtendedVulnerability, e *config.Exclusion) bool {
+	if e.MaxSeverity != "" {
+		s, err := FindSeverity(e.MaxSeverity)
+		if err != nil || v.Severity.Threshold > s.Threshold {
+			return false
+		}
+	}
+	return e.Target.Match(v.Target) &&
+		e.Summary.Match(v.Summary) &&
+		e.Fingerprint.Match(v.Fingerprint) &&
+		(e.AffectedResource.Match(v.AffectedResource) || e.AffectedResource.Match(v.AffectedResourceString)) &&
+		e.Checktype.Match(v.ChecktypeName) &&
+		e.AssetType.Match(v.AssetType)
 }
 
 func updateReport(e *ExtendedVulnerability, c *config.Check) {
@@ -91,8 +110,18 @@ func updateReport(e *ExtendedVulnerability, c *config.Check) {
 	}
 }
 
-func parseReports(reports map[string]*report.Report, cfg *config.Config, l log.Logger) []ExtendedVulnerability {
+// originalTarget returns the target of the check as defined in the config
+// instead of the one sent to the agent.
+func originalTarget(cfg *config.Config, r *report.Report) string {
+	if c := config.GetCheckById(cfg, r.CheckID); c != nil {
+		return c.Target
+	}
+	return r.Target
+}
+
+func parseReports(reports map[string]*report.Report, cfg *config.Config, b baseline, l log.Logger) []ExtendedVulnerability {
 	vulns := []ExtendedVulnerability{}
+	now := time.Now()
 	for _, r := range reports {
 		for i := range r.Vulnerabilities {
 			v := r.Vulnerabilities[i]
@@ -103,11 +132,14 @@ func parseReports(reports map[string]*report.Report, cfg *config.Config, l log.L
 			}
 			for _, s := range cfg.Checks {
 				if s.Id == r.CheckID {
+					extended.AssetType = s.AssetType
 					updateReport(&extended, &s)
 					break
 				}
 			}
-			extended.Excluded = isExcluded(&extended, &cfg.Reporting.Exclusions)
+			extended.Exclusion = findExclusion(&extended, cfg.Reporting.Exclusions, now)
+			extended.Excluded = extended.Exclusion != nil
+			extended.Known = b.contains(&extended)
 			vulns = append(vulns, extended)
 		}
 	}
@@ -140,9 +172,182 @@ func FindSeverityByScore(score float32) *Severity {
 	return &severities[len(severities)-1]
 }
 
-func Generate(cfg *config.Config, results *results.ResultsServer, l log.Logger) (int, error) {
-	if cfg.Reporting.Format != "json" {
-		return 1, fmt.Errorf("report format unknown %s", cfg.Reporting.Format)
+// reportData contains the information available to the report writers.
+type reportData struct {
+	cfg       *config.Config
+	reports   map[string]*report.Report
+	vulns     []ExtendedVulnerability
+	threshold *Severity
+	run       *RunInfo
+	// unfiltered includes the vulnerabilities below the threshold and the
+	// excluded ones, annotated, in the formats supporting it.
+	unfiltered bool
+}
+
+// belowThreshold returns true if the severity of the vulnerability is below
+// the threshold.
+func (d *reportData) belowThreshold(v *ExtendedVulnerability) bool {
+	return v.Severity.Threshold < d.threshold.Threshold
+}
+
+// formatter writes the report data in a concrete format.
+type formatter func(w io.Writer, data *reportData) error
+
+var formatters = map[string]formatter{
+	"json":      writeJSON,
+	"sarif":     writeSARIF,
+	"junit":     writeJUnit,
+	"html":      writeHTML,
+	"markdown":  writeMarkdown,
+	"cyclonedx": writeCycloneDX,
+	"csv":       writeCSV,
+	// The native format with the run metadata.
+	"vulcan-local": writeNative,
+}
+
+// FormatNames returns the list of supported report formats.
+func FormatNames() []string {
+	names := []string{}
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsFormat returns true if the report format is supported.
+func IsFormat(name string) bool {
+	_, ok := formatters[name]
+	return ok
+}
+
+// isReported returns true if the vulnerability is not excluded and its
+// severity is over the threshold.
+func (v *ExtendedVulnerability) isReported(threshold *Severity) bool {
+	return !v.Excluded && v.Severity.Threshold >= threshold.Threshold
+}
+
+// affectedResource returns the human-readable affected resource when present.
+func (v *ExtendedVulnerability) affectedResource() string {
+	return affectedResource(v.Vulnerability)
+}
+
+// affectedResource returns the human-readable affected resource of the
+// vulnerability when present.
+func affectedResource(v *report.Vulnerability) string {
+	if v.AffectedResourceString != "" {
+		return v.AffectedResourceString
+	}
+	return v.AffectedResource
+}
+
+// annotatedVulnerability is a vulnerability of the unfiltered json report.
+type annotatedVulnerability struct {
+	report.Vulnerability
+	Excluded       bool   `json:"excluded"`
+	ExclusionRef   string `json:"exclusionRef,omitempty"`
+	BelowThreshold bool   `json:"belowThreshold"`
+}
+
+// annotatedReport is a report of the unfiltered json report.
+type annotatedReport struct {
+	report.Report
+	Vulnerabilities []annotatedVulnerability `json:"vulnerabilities"`
+}
+
+// exclusionRef returns the location of the exclusion or its fields when it
+// was not defined in a file.
+func exclusionRef(e *config.Exclusion) string {
+	if loc := e.Locate().String(); loc != "" {
+		return loc
+	}
+	return exclusionString(e)
+}
+
+// writeJSON writes the reports as a json slice. Unless unfiltered, the
+// excluded vulnerabilities and the ones below the threshold are left out.
+func writeJSON(w io.Writer, data *reportData) error {
+	if data.unfiltered {
+		return writeUnfilteredJSON(w, data)
+	}
+	// Recreates the original report map filtering the Excluded and Threshold
+	m := map[string]*report.Report{}
+	slice := []*report.Report{}
+	for _, e := range data.vulns {
+		r, ok := m[e.CheckID]
+		if !ok {
+			r = &report.Report{CheckData: *e.CheckData}
+			m[e.CheckID] = r
+			slice = append(slice, r)
+		}
+		if e.isReported(data.threshold) {
+			r.Vulnerabilities = append(r.Vulnerabilities, *(e.Vulnerability))
+		}
+	}
+	str, err := json.Marshal(slice)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(str)
+	return err
+}
+
+// writeUnfilteredJSON writes all the vulnerabilities annotated with the
+// exclusion matching them and whether they are below the threshold.
+func writeUnfilteredJSON(w io.Writer, data *reportData) error {
+	m := map[string]*annotatedReport{}
+	slice := []*annotatedReport{}
+	for i := range data.vulns {
+		e := &data.vulns[i]
+		r, ok := m[e.CheckID]
+		if !ok {
+			r = &annotatedReport{Report: report.Report{CheckData: *e.CheckData}}
+			m[e.CheckID] = r
+			slice = append(slice, r)
+		}
+		v := annotatedVulnerability{
+			Vulnerability:  *e.Vulnerability,
+			Excluded:       e.Excluded,
+			BelowThreshold: data.belowThreshold(e),
+		}
+		if e.Exclusion != nil {
+			v.ExclusionRef = exclusionRef(e.Exclusion)
+		}
+		r.Vulnerabilities = append(r.Vulnerabilities, v)
+	}
+	str, err := json.Marshal(slice)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(str)
+	return err
+}
+
+// writeOutput writes the report in the requested format into outputFile (stderr if "-").
+func writeOutput(outputFile string, f formatter, data *reportData) error {
+	if outputFile == "-" {
+		return f(os.Stderr, data)
+	}
+	file, err := os.OpenFile(outputFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open report file %s %+v", outputFile, err)
+	}
+	defer file.Close()
+	if err := f(file, data); err != nil {
+		return fmt.Errorf("unable to write report file %s %+v", outputFile, err)
+	}
+	return nil
+}
+
+// Generate prints the summary and the details of the vulnerabilities in the
+// reports, writes the output file and returns the exit code. The run contains
+// the metadata of the scan included in the vulcan-local format.
+func Generate(cfg *config.Config, reports map[string]*report.Report, run *RunInfo, l log.Logger) (int, error) {
+	outputs := cfg.Reporting.AllOutputs()
+	for _, o := range outputs {
+		if !IsFormat(o.Format) {
+			return ErrorExitCode, fmt.Errorf("report format unknown %s, allowed values %v", o.Format, FormatNames())
+		}
 	}
 
 	requested, err := FindSeverity(cfg.Reporting.Threshold)
@@ -150,50 +355,47 @@ func Generate(cfg *config.Config, results *results.ResultsServer, l log.Logger)
 		return ErrorExitCode, err
 	}
 
+	var b baseline
+	if cfg.Reporting.Baseline != "" {
+		if b, err = loadBaseline(cfg.Reporting.Baseline); err != nil {
+			return ErrorExitCode, fmt.Errorf("unable to load baseline %s %+v", cfg.Reporting.Baseline, err)
+		}
+	}
+
 	// Print results when no output file is set
-	vs := parseReports(results.Checks, cfg, l)
+	vs := parseReports(reports, cfg, b, l)
+
+	var stats *baselineStats
+	if b != nil {
+		stats = b.compare(vs)
+	}
 
 	// Print summary table
-	summaryTable(vs, l)
-
-	outputFile := cfg.Reporting.OutputFile
-	if outputFile != "" {
-
-		// TODO: Decide if we want to keep filtering JSON output by threshold and exclusion
-		// Recreates the original report map filtering the Excluded and Threshold
-		// json: Just print the reports as an slice
-		m := map[string]*report.Report{}
-		slice := []*report.Report{}
-		for _, e := range vs {
-			r, ok := m[e.CheckID]
-			if !ok {
-				r = &report.Report{CheckData: *e.CheckData}
-				m[e.CheckID] = r
-				slice = append(slice, r)
-			}
-			if !e.Excluded && e.Severity.Threshold >= requested.Threshold {
-				r.Vulnerabilities = append(r.Vulnerabilities, *(e.Vulnerability))
-			}
-		}
-		str, _ := json.Marshal(slice)
-		if outputFile == "-" {
-			fmt.Fprint(os.Stderr, string(str))
-		} else {
-			f, err := os.OpenFile(outputFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-			if err != nil {
-				return 1, fmt.Errorf("unable to open report file %s %+v", outputFile, err)
-			}
-			defer f.Close()
-			if _, err := f.Write(str); err != nil {
-				return 1, fmt.Errorf("unable to write report file %s %+v", outputFile, err)
-			}
+	summaryTable(vs, stats, cfg.Reporting.Exclusions, l)
+
+	checks := checkResults(cfg, reports)
+	statusTable(checks, cfg.Reporting.OnCheckError, l)
+
+	// All the outputs are written from the same parsed vulnerabilities.
+	data := &reportData{
+		cfg:       cfg,
+		reports:   reports,
+		vulns:     vs,
+		threshold: requested,
+		run:       run,
+	}
+	for _, o := range outputs {
+		data.unfiltered = o.Unfiltered
+		if err := writeOutput(o.File, formatters[o.Format], data); err != nil {
+			return ErrorExitCode, err
 		}
+		l.Debugf("Report written format=%s file=%s", o.Format, o.File)
 	}
 
 	var rs string
 	for _, s := range severities {
 		for _, v := range vs {
-			if v.Severity.Name == s.Name && !v.Excluded && v.Severity.Threshold >= requested.Threshold {
+			if v.Severity.Name == s.Name && v.isReported(requested) && !v.Known {
 				rs = fmt.Sprintf("%s%s", rs, printVulnerability(&v, l))
 			}
 		}
@@ -202,17 +404,46 @@ func Generate(cfg *config.Config, results *results.ResultsServer, l log.Logger)
 		l.Infof("\nVulnerabilities details:\n%s", rs)
 	}
 
+	exit := thresholdExit(vs, requested)
+	if len(cfg.Reporting.Policy) > 0 {
+		var results []policyResult
+		results, exit = evaluatePolicy(cfg.Reporting.Policy, vs, checks)
+		printPolicy(results, exit, l)
+	}
+
+	if cfg.Reporting.OnCheckError == config.OnCheckErrorFail && exit < CheckFailedExitCode {
+		incomplete := 0
+		for i := range checks {
+			if !checks[i].completed() {
+				incomplete++
+			}
+		}
+		if incomplete > 0 {
+			l.Infof("%d checks didn't complete, exit code %d", incomplete, CheckFailedExitCode)
+			exit = CheckFailedExitCode
+		}
+	}
+	return exit, nil
+}
+
+// thresholdExit returns the exit code of the max severity of the
+// vulnerabilities if it is over the threshold.
+func thresholdExit(vs []ExtendedVulnerability, requested *Severity) int {
 	// Get max reported score in vulnerabilities
 	var maxScore float32 = -1.0
 	for _, v := range vs {
+		// Vulnerabilities already present in the baseline don't break the build.
+		if v.Known {
+			continue
+		}
 		if v.Score > float32(maxScore) {
 			maxScore = v.Score
 		}
 	}
 
 	if current := FindSeverityByScore(maxScore); current.Threshold >= requested.Threshold {
-		return current.Exit, nil
+		return current.Exit
 	}
 
-	return 0, nil
+	return SuccessExitCode
 }
@@ -1,7 +1,17 @@
 package reporting
 
 import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	report "github.com/adevinta/vulcan-report"
+	"github.mpi-internal.com/spt-security/vulcan-local/pkg/config"
 )
 
 func TestFindSeverity(t *testing.T) {
@@ -80,3 +90,766 @@ func TestFindSeverityByScore(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteSARIF(t *testing.T) {
+	high, _ := FindSeverity("HIGH")
+	check := &report.CheckData{CheckID: "1", ChecktypeName: "vulcan-zap", Target: "http://localhost"}
+	vulns := []ExtendedVulnerability{
+		{
+			CheckData:     check,
+			Vulnerability: &report.Vulnerability{Summary: "XSS", Score: 7.5, AffectedResource: "/index.html", Fingerprint: "abc"},
+			Severity:      FindSeverityByScore(7.5),
+		},
+		{
+			CheckData:     check,
+			Vulnerability: &report.Vulnerability{Summary: "XSS", Score: 9.0, Fingerprint: "def"},
+			Severity:      FindSeverityByScore(9.0),
+		},
+		{ // Excluded
+			CheckData:     check,
+			Vulnerability: &report.Vulnerability{Summary: "Leaked", Score: 9.0},
+			Severity:      FindSeverityByScore(9.0),
+			Excluded:      true,
+		},
+		{ // Below threshold
+			CheckData:     check,
+			Vulnerability: &report.Vulnerability{Summary: "Header", Score: 4.0},
+			Severity:      FindSeverityByScore(4.0),
+		},
+	}
+	buf := new(bytes.Buffer)
+	if err := writeSARIF(buf, &reportData{vulns: vulns, threshold: high}); err != nil {
+		t.Fatalf("writeSARIF returned error %+v", err)
+	}
+	var got sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid sarif output %+v", err)
+	}
+	run := got.Runs[0]
+	if len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != "vulcan-zap/XSS" {
+		t.Fatalf("unexpected rules %+v", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("expected 2 results got %d", len(run.Results))
+	}
+	if r := run.Results[0]; r.Level != "error" || r.PartialFingerprints["vulcanFingerprint/v1"] != "def" || r.Locations[0].PhysicalLocation.ArtifactLocation.URI != "http://localhost" {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if r := run.Results[1]; r.Locations[0].PhysicalLocation.ArtifactLocation.URI != "/index.html" {
+		t.Fatalf("unexpected result location %+v", r)
+	}
+}
+
+func TestWriteNative(t *testing.T) {
+	high, _ := FindSeverity("HIGH")
+	cfg := &config.Config{Checks: []config.Check{
+		{Id: "1", Type: "vulcan-zap", Target: "http://localhost:1234", NewTarget: "http://host.docker.internal:1234", AssetType: "WebAddress"},
+	}}
+	reports := map[string]*report.Report{
+		"1": {CheckData: report.CheckData{CheckID: "1", ChecktypeName: "vulcan-zap", Target: "http://host.docker.internal:1234", Status: "FINISHED"}},
+	}
+	exclusion := &config.Exclusion{Summary: newMatcher(config.MatchContains, "Leaked"), Reason: "false positive"}
+	vulns := []ExtendedVulnerability{
+		{
+			CheckData:     &reports["1"].CheckData,
+			Vulnerability: &report.Vulnerability{Summary: "Leaked", Score: 9.0},
+			Severity:      FindSeverityByScore(9.0),
+			Excluded:      true,
+			Exclusion:     exclusion,
+		},
+		{ // Below threshold
+			CheckData:     &reports["1"].CheckData,
+			Vulnerability: &report.Vulnerability{Summary: "Header", Score: 4.0},
+			Severity:      FindSeverityByScore(4.0),
+		},
+	}
+	run := &RunInfo{ID: "run", Version: "v1.0.0", ConfigHash: "abc"}
+	buf := new(bytes.Buffer)
+	if err := writeNative(buf, &reportData{cfg: cfg, reports: reports, vulns: vulns, threshold: high, run: run}); err != nil {
+		t.Fatalf("writeNative returned error %+v", err)
+	}
+	var got NativeReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid native output %+v", err)
+	}
+	if got.SchemaVersion != NativeSchemaVersion || got.Run.ID != "run" || got.Run.Version != "v1.0.0" || got.Run.ConfigHash != "abc" {
+		t.Fatalf("unexpected metadata %+v", got)
+	}
+	if len(got.Checks) != 1 || got.Checks[0].OriginalTarget != "http://localhost:1234" || got.Checks[0].AssetType != "WebAddress" || got.Checks[0].Status != "FINISHED" {
+		t.Fatalf("unexpected checks %+v", got.Checks)
+	}
+	if len(got.Vulnerabilities) != 2 {
+		t.Fatalf("expected 2 vulnerabilities got %d", len(got.Vulnerabilities))
+	}
+	if v := got.Vulnerabilities[0]; !v.Excluded || v.BelowThreshold || v.Severity != "CRITICAL" || v.Exclusion == nil || v.Exclusion.Reason != "false positive" {
+		t.Fatalf("unexpected vulnerability %+v", v)
+	}
+	if v := got.Vulnerabilities[1]; v.Excluded || !v.BelowThreshold || v.Severity != "MEDIUM" {
+		t.Fatalf("unexpected vulnerability %+v", v)
+	}
+}
+
+func TestReadSavedRun(t *testing.T) {
+	high, _ := FindSeverity("HIGH")
+	cfg := &config.Config{Checks: []config.Check{
+		{Id: "1", Type: "vulcan-zap", Target: "http://localhost:1234", NewTarget: "http://host.docker.internal:1234", AssetType: "WebAddress"},
+	}}
+	reports := map[string]*report.Report{
+		"1": {CheckData: report.CheckData{CheckID: "1", ChecktypeName: "vulcan-zap", Target: "http://host.docker.internal:1234", Status: "FINISHED"}},
+	}
+	vulns := []ExtendedVulnerability{
+		{
+			CheckData:     &reports["1"].CheckData,
+			Vulnerability: &report.Vulnerability{Summary: "XSS", Score: 7.5},
+			Severity:      FindSeverityByScore(7.5),
+		},
+		{ // Below threshold
+			CheckData:     &reports["1"].CheckData,
+			Vulnerability: &report.Vulnerability{Summary: "Header", Score: 4.0},
+			Severity:      FindSeverityByScore(4.0),
+		},
+	}
+	path := filepath.Join(t.TempDir(), "results.json")
+	data := &reportData{cfg: cfg, reports: reports, vulns: vulns, threshold: high, run: &RunInfo{ID: "run"}}
+	if err := writeOutput(path, writeNative, data); err != nil {
+		t.Fatalf("writeOutput returned error %+v", err)
+	}
+	saved, err := ReadSavedRun(path)
+	if err != nil {
+		t.Fatalf("ReadSavedRun returned error %+v", err)
+	}
+	if saved.Run == nil || saved.Run.ID != "run" || len(saved.Checks) != 1 || saved.Checks[0].AssetType != "WebAddress" {
+		t.Fatalf("unexpected saved run %+v", saved)
+	}
+	if len(saved.Reports) != 1 || saved.Reports[0].Target != "http://localhost:1234" || len(saved.Reports[0].Vulnerabilities) != 2 {
+		t.Fatalf("unexpected reports %+v", saved.Reports)
+	}
+
+	// The exclusions and asset types are applied offline.
+	cfg = &config.Config{Checks: saved.Checks}
+	cfg.Reporting.Exclusions = []config.Exclusion{{Summary: newMatcher(config.MatchContains, "XSS")}}
+	vs := parseReports(map[string]*report.Report{"1": &saved.Reports[0]}, cfg, nil, nil)
+	if len(vs) != 2 || !vs[0].Excluded || vs[0].AssetType != "WebAddress" {
+		t.Fatalf("unexpected vulnerabilities %+v", vs)
+	}
+}
+
+func TestWriteUnfilteredJSON(t *testing.T) {
+	high, _ := FindSeverity("HIGH")
+	check := &report.CheckData{CheckID: "1", ChecktypeName: "vulcan-zap", Target: "http://localhost"}
+	vulns := []ExtendedVulnerability{
+		{
+			CheckData:     check,
+			Vulnerability: &report.Vulnerability{Summary: "XSS", Score: 7.5},
+			Severity:      FindSeverityByScore(7.5),
+		},
+		{
+			CheckData:     check,
+			Vulnerability: &report.Vulnerability{Summary: "Leaked", Score: 9.0},
+			Severity:      FindSeverityByScore(9.0),
+			Excluded:      true,
+			Exclusion:     &config.Exclusion{Summary: newMatcher(config.MatchContains, "Leaked")},
+		},
+		{
+			CheckData:     check,
+			Vulnerability: &report.Vulnerability{Summary: "Header", Score: 4.0},
+			Severity:      FindSeverityByScore(4.0),
+		},
+	}
+	for _, c := range []struct {
+		unfiltered bool
+		expected   string
+	}{
+		{false, `[{"vulnerabilities":[{"summary":"XSS"}]}]`},
+		{true, `[{"vulnerabilities":[{"summary":"XSS","excluded":false,"belowThreshold":false},{"summary":"Leaked","excluded":true,"exclusionRef":"summary=\"Leaked\"","belowThreshold":false},{"summary":"Header","excluded":false,"belowThreshold":true}]}]`},
+	} {
+		buf := new(bytes.Buffer)
+		if err := writeJSON(buf, &reportData{vulns: vulns, threshold: high, unfiltered: c.unfiltered}); err != nil {
+			t.Fatalf("writeJSON returned error %+v", err)
+		}
+		// Compare only the fields of the test.
+		var got []struct {
+			Vulnerabilities []struct {
+				Summary        string `json:"summary"`
+				Excluded       *bool  `json:"excluded,omitempty"`
+				ExclusionRef   string `json:"exclusionRef,omitempty"`
+				BelowThreshold *bool  `json:"belowThreshold,omitempty"`
+			} `json:"vulnerabilities"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("invalid json output %+v", err)
+		}
+		b, _ := json.Marshal(got)
+		if string(b) != c.expected {
+			t.Fatalf("unfiltered=%v unexpected json %s expected %s", c.unfiltered, b, c.expected)
+		}
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	high, _ := FindSeverity("HIGH")
+	check := &report.CheckData{CheckID: "1", ChecktypeName: "vulcan-trivy", Target: "."}
+	vulns := []ExtendedVulnerability{}
+	for i := 0; i < 10; i++ {
+		vulns = append(vulns, ExtendedVulnerability{
+			CheckData: check,
+			Vulnerability: &report.Vulnerability{
+				Summary:     fmt.Sprintf("CVE-%d", i),
+				Score:       9.0,
+				Description: strings.Repeat("x", 500),
+				Resources: []report.ResourcesGroup{{
+					Name:   "Packages",
+					Header: []string{"Name", "Version"},
+					Rows:   []map[string]string{{"Name": "a|b", "Version": "1.0\n2.0"}},
+				}},
+			},
+			Severity: FindSeverityByScore(9.0),
+		})
+	}
+	cfg := &config.Config{Reporting: config.Reporting{MarkdownMaxSize: 3000}}
+	buf := new(bytes.Buffer)
+	if err := writeMarkdown(buf, &reportData{cfg: cfg, vulns: vulns, threshold: high}); err != nil {
+		t.Fatalf("writeMarkdown returned error %+v", err)
+	}
+	md := buf.String()
+	if len(md) > 3000 {
+		t.Fatalf("markdown size %d over the limit", len(md))
+	}
+	if !strings.Contains(md, "| CRITICAL | 10 |") || !strings.Contains(md, "| a\\|b | 1.0<br>2.0 |") {
+		t.Fatalf("unexpected markdown %s", md)
+	}
+	if n := strings.Count(md, "<details>"); n == 0 || n == 10 || !strings.Contains(md, fmt.Sprintf("%d vulnerabilities are not shown", 10-n)) {
+		t.Fatalf("unexpected truncation %s", md)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	high, _ := FindSeverity("HIGH")
+	zap := &report.CheckData{CheckID: "1", ChecktypeName: "vulcan-zap", Target: "http://b.example.com"}
+	headers := &report.CheckData{CheckID: "2", ChecktypeName: "vulcan-http-headers", Target: "http://b.example.com"}
+	trivy := &report.CheckData{CheckID: "3", ChecktypeName: "vulcan-trivy", Target: "a.example.com"}
+	vuln := func(check *report.CheckData, summary string, score float32, excluded bool) ExtendedVulnerability {
+		return ExtendedVulnerability{
+			CheckData:     check,
+			Vulnerability: &report.Vulnerability{Summary: summary, Score: score},
+			Severity:      FindSeverityByScore(score),
+			Excluded:      excluded,
+		}
+	}
+	vulns := []ExtendedVulnerability{
+		vuln(zap, "<script>alert(1)</script>", 9.0, false),
+		vuln(zap, "Excluded XSS", 9.0, true),
+		vuln(headers, "Missing header", 2.0, false),
+		vuln(trivy, "CVE-1", 7.0, false),
+	}
+	buf := new(bytes.Buffer)
+	if err := writeHTML(buf, &reportData{vulns: vulns, threshold: high}); err != nil {
+		t.Fatalf("writeHTML returned error %+v", err)
+	}
+	html := buf.String()
+
+	// Grouped by target and then by checktype, both sorted.
+	order := []string{
+		"<h2>a.example.com</h2>", "<h3>vulcan-trivy</h3>",
+		"<h2>http://b.example.com</h2>", "<h3>vulcan-http-headers</h3>", "<h3>vulcan-zap</h3>",
+	}
+	last := -1
+	for _, s := range order {
+		i := strings.Index(html, s)
+		if i <= last {
+			t.Fatalf("%s is not in order in the html report", s)
+		}
+		last = i
+	}
+
+	if strings.Contains(html, "<script>alert(1)</script>") || !strings.Contains(html, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+		t.Errorf("summary is not escaped")
+	}
+
+	// The excluded vulnerabilities and the severities below the threshold are
+	// rendered but hidden by the unchecked filters.
+	if !strings.Contains(html, `data-severity="CRITICAL" data-excluded="true"`) {
+		t.Errorf("missing excluded vulnerability")
+	}
+	if !strings.Contains(html, `<input type="checkbox" id="excluded-filter"> Excluded`) {
+		t.Errorf("excluded vulnerabilities are shown by default")
+	}
+	for name, checked := range map[string]bool{"CRITICAL": true, "HIGH": true, "MEDIUM": false, "LOW": false} {
+		filter := fmt.Sprintf(`class="severity-filter" value="%s" checked>`, name)
+		if strings.Contains(html, filter) != checked {
+			t.Errorf("got severity filter %s checked=%v, expected %v", name, !checked, checked)
+		}
+	}
+	if !strings.Contains(html, "EXCLUDED<span>1</span>") || !strings.Contains(html, "CRITICAL<span>1</span>") {
+		t.Errorf("unexpected counts in the summary")
+	}
+}
+
+func TestWriteCycloneDX(t *testing.T) {
+	high, _ := FindSeverity("HIGH")
+	check := &report.CheckData{CheckID: "1", ChecktypeName: "vulcan-trivy", Target: "."}
+	vulns := []ExtendedVulnerability{
+		{
+			CheckData: check,
+			Vulnerability: &report.Vulnerability{
+				Summary:          "Prototype pollution in lodash",
+				Score:            9.1,
+				AffectedResource: "lodash",
+				References:       []string{"https://nvd.nist.gov/vuln/detail/CVE-2019-10744", "GHSA-jf85-cpcp-j695"},
+			},
+			Severity: FindSeverityByScore(9.1),
+		},
+		{ // Excluded
+			CheckData:     check,
+			Vulnerability: &report.Vulnerability{Summary: "Secret in repository", Score: 8.0},
+			Severity:      FindSeverityByScore(8.0),
+			Excluded:      true,
+			Exclusion:     &config.Exclusion{Reason: "test fixture", Owner: "team"},
+		},
+	}
+	buf := new(bytes.Buffer)
+	if err := writeCycloneDX(buf, &reportData{vulns: vulns, threshold: high}); err != nil {
+		t.Fatalf("writeCycloneDX returned error %+v", err)
+	}
+	var got cyclonedxBOM
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid cyclonedx output %+v", err)
+	}
+	if got.BOMFormat != "CycloneDX" || len(got.Components) != 2 || len(got.Vulnerabilities) != 2 {
+		t.Fatalf("unexpected bom %+v", got)
+	}
+	v := got.Vulnerabilities[0]
+	if v.ID != "CVE-2019-10744" || v.Source.Name != "NVD" || len(v.References) != 1 || v.References[0].ID != "GHSA-jf85-cpcp-j695" {
+		t.Fatalf("unexpected vulnerability ids %+v", v)
+	}
+	if v.Ratings[0].Severity != "critical" || v.Ratings[0].Method != "other" || v.Affects[0].Ref != got.Components[0].BOMRef || got.Components[0].Name != "lodash" || v.Analysis != nil {
+		t.Fatalf("unexpected vulnerability %+v", v)
+	}
+	if a := got.Vulnerabilities[1].Analysis; a == nil || a.State != "exploitable" || len(a.Response) != 1 || a.Response[0] != "will_not_fix" || a.Detail != "test fixture, owner: team" {
+		t.Fatalf("unexpected analysis %+v", a)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	high, _ := FindSeverity("HIGH")
+	check := &report.CheckData{CheckID: "1", ChecktypeName: "vulcan-zap", Target: "http://localhost"}
+	vulns := []ExtendedVulnerability{
+		{ // Below threshold
+			CheckData:     check,
+			Vulnerability: &report.Vulnerability{Summary: "Header", Score: 4.0},
+			Severity:      FindSeverityByScore(4.0),
+		},
+		{
+			CheckData:     check,
+			Vulnerability: &report.Vulnerability{Summary: "XSS, reflected", Score: 7.5, CWEID: 79},
+			Severity:      FindSeverityByScore(7.5),
+			Excluded:      true,
+		},
+	}
+	cfg := &config.Config{Reporting: config.Reporting{CSVColumns: []string{"severity", "summary", "cwe", "excluded"}}}
+	buf := new(bytes.Buffer)
+	if err := writeCSV(buf, &reportData{cfg: cfg, vulns: vulns, threshold: high}); err != nil {
+		t.Fatalf("writeCSV returned error %+v", err)
+	}
+	expected := "severity,summary,cwe,excluded\nHIGH,\"XSS, reflected\",CWE-79,true\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected csv %q expected %q", buf.String(), expected)
+	}
+	cfg.Reporting.CSVColumns = []string{"unknown"}
+	if err := writeCSV(buf, &reportData{cfg: cfg, vulns: vulns, threshold: high}); err == nil {
+		t.Fatalf("expected error for unknown column")
+	}
+}
+
+func TestCSVEscape(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"XSS", "XSS"},
+		{"", ""},
+		{"7.5", "7.5"},
+		{`=HYPERLINK("http://evil","x")`, `'=HYPERLINK("http://evil","x")`},
+		{"+1", "'+1"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tcmd", "'\tcmd"},
+		{"\rcmd", "'\rcmd"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := csvEscape(tt.value); got != tt.expected {
+			t.Errorf("csvEscape(%q)==%q expected %q", tt.value, got, tt.expected)
+		}
+	}
+
+	high, _ := FindSeverity("HIGH")
+	vulns := []ExtendedVulnerability{{
+		CheckData:     &report.CheckData{CheckID: "1", ChecktypeName: "vulcan-zap", Target: "http://localhost"},
+		Vulnerability: &report.Vulnerability{Summary: "=cmd|' /C calc'!A0", Score: 7.5},
+		Severity:      FindSeverityByScore(7.5),
+	}}
+	cfg := &config.Config{Reporting: config.Reporting{CSVColumns: []string{"summary"}}}
+	buf := new(bytes.Buffer)
+	if err := writeCSV(buf, &reportData{cfg: cfg, vulns: vulns, threshold: high}); err != nil {
+		t.Fatalf("writeCSV returned error %+v", err)
+	}
+	if expected := "summary\n'=cmd|' /C calc'!A0\n"; buf.String() != expected {
+		t.Fatalf("unexpected csv %q expected %q", buf.String(), expected)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	high, _ := FindSeverity("HIGH")
+	reports := map[string]*report.Report{
+		"1": {CheckData: report.CheckData{CheckID: "1", ChecktypeName: "vulcan-zap", Target: "http://localhost", Status: "FINISHED"}},
+		"2": {CheckData: report.CheckData{CheckID: "2", ChecktypeName: "vulcan-exposed-http", Target: "http://localhost", Status: "FINISHED"}},
+		"3": {CheckData: report.CheckData{CheckID: "3", ChecktypeName: "vulcan-retirejs", Target: "http://localhost", Status: "TIMEOUT"}},
+		"4": {CheckData: report.CheckData{CheckID: "4", ChecktypeName: "vulcan-seekret", Target: ".", Status: "FINISHED"}},
+	}
+	vulns := []ExtendedVulnerability{
+		{
+			CheckData:     &reports["1"].CheckData,
+			Vulnerability: &report.Vulnerability{Summary: "XSS", Score: 7.5},
+			Severity:      FindSeverityByScore(7.5),
+		},
+		{
+			CheckData:     &reports["2"].CheckData,
+			Vulnerability: &report.Vulnerability{Summary: "Leaked", Score: 9.0},
+			Severity:      FindSeverityByScore(9.0),
+			Excluded:      true,
+		},
+	}
+	buf := new(bytes.Buffer)
+	data := &reportData{cfg: &config.Config{}, reports: reports, vulns: vulns, threshold: high}
+	if err := writeJUnit(buf, data); err != nil {
+		t.Fatalf("writeJUnit returned error %+v", err)
+	}
+	var got junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid junit output %+v", err)
+	}
+	if got.Tests != 4 || got.Failures != 1 || got.Errors != 1 || got.Skipped != 1 || len(got.Suites) != 2 {
+		t.Fatalf("unexpected junit totals %+v", got)
+	}
+	if s := got.Suites[1]; s.Name != "http://localhost" || s.Cases[2].Failure == nil || s.Cases[0].Skipped == nil || s.Cases[1].Error == nil {
+		t.Fatalf("unexpected testsuite %+v", s)
+	}
+}
+
+func TestBaselineCompare(t *testing.T) {
+	b := baseline{}
+	for _, f := range []string{"a", "b", "c"} {
+		b[vulnerabilityKey("", ".", &report.Vulnerability{Fingerprint: f})] = struct{}{}
+	}
+	check := &report.CheckData{Target: "."}
+	vulns := []ExtendedVulnerability{}
+	for _, f := range []string{"a", "b", "d"} {
+		v := ExtendedVulnerability{CheckData: check, Vulnerability: &report.Vulnerability{Fingerprint: f}}
+		v.Known = b.contains(&v)
+		vulns = append(vulns, v)
+	}
+	if stats := b.compare(vulns); stats.New != 1 || stats.Known != 2 || stats.Fixed != 1 {
+		t.Fatalf("unexpected baseline stats %+v", stats)
+	}
+}
+
+func TestBaselineWithoutFingerprint(t *testing.T) {
+	old := &report.Vulnerability{Summary: "Outdated lodash", AffectedResource: "lodash"}
+	b := baseline{vulnerabilityKey("vulcan-retirejs", "http://localhost", old): struct{}{}}
+	retirejs := &report.CheckData{ChecktypeName: "vulcan-retirejs", Target: "http://localhost"}
+	zap := &report.CheckData{ChecktypeName: "vulcan-zap", Target: "http://localhost"}
+	tests := []struct {
+		check    *report.CheckData
+		vuln     report.Vulnerability
+		expected bool
+	}{
+		{retirejs, report.Vulnerability{Summary: "Outdated lodash", AffectedResource: "lodash"}, true},
+		{retirejs, report.Vulnerability{Summary: "Outdated lodash", AffectedResourceString: "lodash"}, true},
+		{retirejs, report.Vulnerability{Summary: "Outdated lodash", AffectedResource: "lodash@4"}, false},
+		{retirejs, report.Vulnerability{Summary: "Outdated jquery", AffectedResource: "jquery"}, false},
+		{zap, report.Vulnerability{Summary: "Outdated lodash", AffectedResource: "lodash"}, false},
+		{zap, report.Vulnerability{Summary: "XSS"}, false},
+		{retirejs, report.Vulnerability{Summary: "Outdated lodash", AffectedResource: "lodash", Fingerprint: "a"}, false},
+	}
+	for i, tt := range tests {
+		v := ExtendedVulnerability{CheckData: tt.check, Vulnerability: &tt.vuln}
+		if got := b.contains(&v); got != tt.expected {
+			t.Errorf("%d: contains(%s %+v)==%v expected %v", i, tt.check.ChecktypeName, tt.vuln, got, tt.expected)
+		}
+	}
+}
+
+func TestDiffReports(t *testing.T) {
+	previous := []report.Report{{
+		CheckData: report.CheckData{ChecktypeName: "vulcan-zap", Target: "http://localhost"},
+		ResultData: report.ResultData{Vulnerabilities: []report.Vulnerability{
+			{Summary: "Removed", Fingerprint: "a", Score: 5.0},
+			{Summary: "Changed", Fingerprint: "b", Score: 5.0},
+			{Summary: "Same", Fingerprint: "c", Score: 5.0},
+		}},
+	}}
+	current := []report.Report{{
+		CheckData: report.CheckData{ChecktypeName: "vulcan-zap", Target: "http://localhost"},
+		ResultData: report.ResultData{Vulnerabilities: []report.Vulnerability{
+			{Summary: "Changed", Fingerprint: "b", Score: 7.0},
+			{Summary: "Same", Fingerprint: "c", Score: 5.1},
+			{Summary: "Added", Fingerprint: "d", Score: 1.0},
+		}},
+	}}
+	d := DiffReports(previous, current)
+	if len(d.Added) != 1 || d.Added[0].Vulnerability.Summary != "Added" {
+		t.Fatalf("unexpected added %+v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Vulnerability.Summary != "Removed" {
+		t.Fatalf("unexpected removed %+v", d.Removed)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].Severity != "HIGH" || d.Changed[0].PreviousSeverity != "MEDIUM" {
+		t.Fatalf("unexpected changed %+v", d.Changed)
+	}
+}
+
+func TestDiffReportsWithoutFingerprint(t *testing.T) {
+	check := report.CheckData{ChecktypeName: "vulcan-retirejs", Target: "http://localhost"}
+	previous := []report.Report{{
+		CheckData: check,
+		ResultData: report.ResultData{Vulnerabilities: []report.Vulnerability{
+			{Summary: "Outdated lodash", AffectedResource: "lodash", Score: 5.0},
+			{Summary: "Outdated jquery", AffectedResource: "jquery", Score: 5.0},
+			{Summary: "Duplicated", Fingerprint: "a", Score: 5.0},
+			{Summary: "Duplicated", Fingerprint: "a", Score: 5.0},
+		}},
+	}}
+	current := []report.Report{{
+		CheckData: check,
+		ResultData: report.ResultData{Vulnerabilities: []report.Vulnerability{
+			{Summary: "Outdated lodash", AffectedResource: "lodash", Score: 5.0},
+			{Summary: "Outdated moment", AffectedResource: "moment", Score: 5.0},
+			{Summary: "Outdated moment", AffectedResource: "moment", Score: 5.0},
+			{Summary: "Duplicated", Fingerprint: "a", Score: 5.0},
+		}},
+	}}
+	d := DiffReports(previous, current)
+	if len(d.Added) != 2 || d.Added[0].Vulnerability.Summary != "Outdated moment" || d.Added[1].Vulnerability.Summary != "Outdated moment" {
+		t.Fatalf("unexpected added %+v", d.Added)
+	}
+	if len(d.Removed) != 2 || d.Removed[0].Vulnerability.Summary != "Duplicated" || d.Removed[1].Vulnerability.Summary != "Outdated jquery" {
+		t.Fatalf("unexpected removed %+v", d.Removed)
+	}
+	if len(d.Changed) != 0 {
+		t.Fatalf("unexpected changed %+v", d.Changed)
+	}
+}
+
+func TestFindExclusion(t *testing.T) {
+	now := time.Date(2022, 2, 1, 10, 0, 0, 0, time.UTC)
+	exclusions := []config.Exclusion{
+		{Summary: newMatcher(config.MatchContains, "Expired"), Expires: config.Date{Time: time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)}},
+		{Summary: newMatcher(config.MatchContains, "Valid"), Expires: config.Date{Time: time.Date(2022, 2, 2, 0, 0, 0, 0, time.UTC)}},
+		{Summary: newMatcher(config.MatchContains, "Stale")},
+	}
+	check := &report.CheckData{Target: "."}
+	vulns := []ExtendedVulnerability{}
+	for _, s := range []string{"Expired", "Valid"} {
+		v := ExtendedVulnerability{CheckData: check, Vulnerability: &report.Vulnerability{Summary: s}}
+		v.Exclusion = findExclusion(&v, exclusions, now)
+		vulns = append(vulns, v)
+	}
+	if vulns[0].Exclusion != nil {
+		t.Fatalf("expired exclusion applied %+v", vulns[0].Exclusion)
+	}
+	if vulns[1].Exclusion != &exclusions[1] {
+		t.Fatalf("unexpected exclusion %+v", vulns[1].Exclusion)
+	}
+	w := exclusionWarnings(vulns, exclusions, now)
+	if !strings.Contains(w, `summary="Expired" expires=2022-02-01`) || !strings.Contains(w, `summary="Stale"`) || strings.Contains(w, `summary="Valid"`) {
+		t.Fatalf("unexpected warnings %s", w)
+	}
+}
+
+func TestExclusionWarningsOverlapping(t *testing.T) {
+	now := time.Date(2022, 2, 1, 10, 0, 0, 0, time.UTC)
+	exclusions := []config.Exclusion{
+		{Summary: newMatcher(config.MatchContains, "XSS")},
+		{Target: newMatcher(config.MatchExact, "http://localhost")},
+		{Summary: newMatcher(config.MatchContains, "Stale")},
+	}
+	check := &report.CheckData{Target: "http://localhost"}
+	v := ExtendedVulnerability{CheckData: check, Vulnerability: &report.Vulnerability{Summary: "XSS"}, Severity: FindSeverityByScore(7.0)}
+	v.Exclusion = findExclusion(&v, exclusions, now)
+	if v.Exclusion != &exclusions[0] {
+		t.Fatalf("unexpected exclusion %+v", v.Exclusion)
+	}
+	w := exclusionWarnings([]ExtendedVulnerability{v}, exclusions, now)
+	if strings.Contains(w, `summary="XSS"`) || strings.Contains(w, `target="http://localhost"`) || !strings.Contains(w, `summary="Stale"`) {
+		t.Fatalf("unexpected warnings %s", w)
+	}
+}
+
+// bufferLogger writes the info messages in a buffer.
+type bufferLogger struct {
+	bytes.Buffer
+}
+
+func (l *bufferLogger) Debugf(format string, args ...interface{}) {}
+func (l *bufferLogger) Infof(format string, args ...interface{}) {
+	fmt.Fprintf(&l.Buffer, format, args...)
+}
+func (l *bufferLogger) Errorf(format string, args ...interface{}) {}
+
+func TestSummaryTablePercent(t *testing.T) {
+	exclusions := []config.Exclusion{{Summary: newMatcher(config.MatchContains, "%s unused 100%d")}}
+	for _, vulns := range [][]ExtendedVulnerability{
+		nil,
+		{{CheckData: &report.CheckData{Target: "http://localhost"}, Vulnerability: &report.Vulnerability{Summary: "XSS", Score: 7.0}}},
+	} {
+		l := &bufferLogger{}
+		summaryTable(vulns, nil, exclusions, l)
+		if out := l.String(); !strings.Contains(out, `summary="%s unused 100%d"`) || strings.Contains(out, "MISSING") {
+			t.Fatalf("unexpected summary %s", out)
+		}
+	}
+}
+
+func TestStatusTablePercent(t *testing.T) {
+	l := &bufferLogger{}
+	statusTable([]checkResult{{Checktype: "vulcan-zap", Target: "http://localhost/%2e%2e/", Status: "FINISHED"}}, "", l)
+	if out := l.String(); !strings.Contains(out, "http://localhost/%2e%2e/") {
+		t.Fatalf("unexpected status %s", out)
+	}
+}
+
+func newMatcher(mode, value string) config.Matcher {
+	m, _ := config.NewMatcher(mode, value)
+	return m
+}
+
+func TestFindExclusionMatchers(t *testing.T) {
+	now := time.Now()
+	exclusions := []config.Exclusion{
+		{Target: newMatcher(config.MatchExact, "http://staging")},
+		{Target: newMatcher(config.MatchGlob, "*.staging.example.com/*"), Checktype: newMatcher(config.MatchExact, "vulcan-zap"), MaxSeverity: "LOW"},
+		{Summary: newMatcher(config.MatchRegex, "^CVE-[0-9]+-[0-9]+$"), AssetType: newMatcher(config.MatchExact, "DockerImage")},
+	}
+	tests := []struct {
+		checktype string
+		target    string
+		summary   string
+		assetType string
+		score     float32
+		expected  *config.Exclusion
+	}{
+		{"vulcan-seekret", "http://staging", "Leaked", "", 9.0, &exclusions[0]},
+		{"vulcan-seekret", "http://staging/", "Leaked", "", 9.0, nil},
+		{"vulcan-zap", "http://app.staging.example.com/login", "Header", "WebAddress", 2.0, &exclusions[1]},
+		{"vulcan-zap", "http://app.staging.example.com/login", "XSS", "WebAddress", 7.0, nil},
+		{"vulcan-retirejs", "http://app.staging.example.com/login", "Header", "WebAddress", 2.0, nil},
+		{"vulcan-trivy", "alpine:3.4", "CVE-2021-1234", "DockerImage", 9.0, &exclusions[2]},
+		{"vulcan-trivy", "alpine:3.4", "CVE-2021-1234 in musl", "DockerImage", 9.0, nil},
+		{"vulcan-trivy", "alpine:3.4", "CVE-2021-1234", "GitRepository", 9.0, nil},
+	}
+	for _, c := range tests {
+		v := ExtendedVulnerability{
+			CheckData:     &report.CheckData{ChecktypeName: c.checktype, Target: c.target},
+			Vulnerability: &report.Vulnerability{Summary: c.summary, Score: c.score},
+			Severity:      FindSeverityByScore(c.score),
+			AssetType:     c.assetType,
+		}
+		if got := findExclusion(&v, exclusions, now); got != c.expected {
+			t.Fatalf("findExclusion(%+v)==%+v expected %+v", c, got, c.expected)
+		}
+	}
+}
+
+func TestDiffPrintPercent(t *testing.T) {
+	d := &Diff{Added: []DiffEntry{{Target: "http://localhost/%2e%2e/", Checktype: "vulcan-zap", Severity: "HIGH",
+		Vulnerability: report.Vulnerability{Summary: "Path %2e traversal", Score: 7.0}}}}
+	l := &bufferLogger{}
+	d.Print(l)
+	if out := l.String(); !strings.Contains(out, "Path %2e traversal") || !strings.Contains(out, "http://localhost/%2e%2e/") {
+		t.Fatalf("unexpected diff %s", out)
+	}
+}
+
+func TestPrintPolicyPercent(t *testing.T) {
+	rule := &config.PolicyRule{Target: newMatcher(config.MatchRegex, "^http://localhost/a%20b")}
+	l := &bufferLogger{}
+	printPolicy([]policyResult{{rule: rule, count: 1, triggered: true, exit: 103}}, 103, l)
+	if out := l.String(); !strings.Contains(out, "target=regex:^http://localhost/a%20b") {
+		t.Fatalf("unexpected policy %s", out)
+	}
+}
+
+func TestGenerateOnCheckError(t *testing.T) {
+	tests := []struct {
+		name         string
+		onCheckError string
+		status       string
+		score        float32
+		policy       []config.PolicyRule
+		expected     int
+	}{
+		{"fail with incomplete check", config.OnCheckErrorFail, "FAILED", 0, nil, CheckFailedExitCode},
+		{"fail with aborted check", config.OnCheckErrorFail, "ABORTED", 0, nil, CheckFailedExitCode},
+		{"fail over severity exit", config.OnCheckErrorFail, "TIMEOUT", 9.5, nil, CheckFailedExitCode},
+		{"fail with finished checks", config.OnCheckErrorFail, "FINISHED", 0, nil, SuccessExitCode},
+		{"fail with finished checks and vulnerability", config.OnCheckErrorFail, "FINISHED", 7.5, nil, 103},
+		{"warn", config.OnCheckErrorWarn, "FAILED", 0, nil, SuccessExitCode},
+		{"default", "", "FAILED", 7.5, nil, 103},
+		{"ignore", config.OnCheckErrorIgnore, "FAILED", 0, nil, SuccessExitCode},
+		{"fail over policy exit", config.OnCheckErrorFail, "FAILED", 7.5, []config.PolicyRule{{MinSeverity: "HIGH"}}, CheckFailedExitCode},
+		{"policy exit over fail", config.OnCheckErrorFail, "FAILED", 0, []config.PolicyRule{{CheckStatus: []string{"FAILED"}, ExitCode: 110}}, 110},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reports := map[string]*report.Report{
+				"1": {CheckData: report.CheckData{CheckID: "1", ChecktypeName: "vulcan-zap", Target: "http://localhost", Status: tt.status}},
+				"2": {CheckData: report.CheckData{CheckID: "2", ChecktypeName: "vulcan-seekret", Target: ".", Status: "FINISHED"}},
+			}
+			if tt.score > 0 {
+				reports["2"].Vulnerabilities = []report.Vulnerability{{Summary: "Leaked", Score: tt.score}}
+			}
+			cfg := &config.Config{Reporting: config.Reporting{Threshold: "HIGH", OnCheckError: tt.onCheckError, Policy: tt.policy}}
+			exit, err := Generate(cfg, reports, nil, &bufferLogger{})
+			if err != nil {
+				t.Fatalf("unexpected error %+v", err)
+			}
+			if exit != tt.expected {
+				t.Errorf("exit==%d expected %d", exit, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEvaluatePolicy(t *testing.T) {
+	rules := []config.PolicyRule{
+		{Action: config.PolicyIgnore, Checktype: newMatcher(config.MatchExact, "vulcan-zap"), MaxSeverity: "HIGH"},
+		{MinSeverity: "HIGH", MaxSeverity: "HIGH", MaxCount: 1},
+		{CheckStatus: []string{"FAILED", "TIMEOUT"}, ExitCode: 2},
+	}
+	zap := &report.CheckData{ChecktypeName: "vulcan-zap", Target: "http://localhost"}
+	trivy := &report.CheckData{ChecktypeName: "vulcan-trivy", Target: "alpine"}
+	vulns := []ExtendedVulnerability{}
+	for _, v := range []struct {
+		check *report.CheckData
+		score float32
+	}{{zap, 7.0}, {zap, 7.5}, {trivy, 7.0}, {trivy, 2.0}} {
+		vulns = append(vulns, ExtendedVulnerability{CheckData: v.check, Vulnerability: &report.Vulnerability{Score: v.score}, Severity: FindSeverityByScore(v.score)})
+	}
+	results, exit := evaluatePolicy(rules, vulns, []checkResult{{Checktype: "vulcan-seekret", Target: ".", Status: "FINISHED"}})
+	if exit != SuccessExitCode || results[0].count != 2 || results[1].count != 1 || results[1].triggered {
+		t.Fatalf("unexpected policy results exit=%d %+v", exit, results)
+	}
+	vulns = append(vulns, vulns[2])
+	results, exit = evaluatePolicy(rules, vulns, []checkResult{{Checktype: "vulcan-seekret", Target: ".", Status: "TIMEOUT"}})
+	if exit != 103 || !results[1].triggered || results[1].exit != 103 || !results[2].triggered || results[2].exit != 2 {
+		t.Fatalf("unexpected policy results exit=%d %+v", exit, results)
+	}
+
+	// Rules triggered without matches use the default exit codes.
+	rules = []config.PolicyRule{{MinSeverity: "CRITICAL", MaxCount: -1}, {CheckStatus: []string{"FAILED"}, MaxCount: -1}}
+	results, exit = evaluatePolicy(rules, nil, nil)
+	if exit != CheckFailedExitCode || results[0].exit != 101 || results[1].exit != CheckFailedExitCode {
+		t.Fatalf("unexpected policy results exit=%d %+v", exit, results)
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package reporting
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json"
+	toolName     = "vulcan-local"
+)
+
+// sarifLevels maps the severity names to SARIF result levels.
+var sarifLevels = map[string]string{
+	"CRITICAL": "error",
+	"HIGH":     "error",
+	"MEDIUM":   "warning",
+	"LOW":      "note",
+	"ALL":      "none",
+}
+
+// Subset of the SARIF 2.1.0 object model required to report the vulnerabilities.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string              `json:"id"`
+	Name             string              `json:"name"`
+	ShortDescription sarifMessage        `json:"shortDescription"`
+	FullDescription  *sarifMessage       `json:"fullDescription,omitempty"`
+	HelpURI          string              `json:"helpUri,omitempty"`
+	Properties       sarifRuleProperties `json:"properties"`
+}
+
+type sarifRuleProperties struct {
+	SecuritySeverity string   `json:"security-severity"`
+	Tags             []string `json:"tags"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// writeSARIF writes the reported vulnerabilities as a SARIF 2.1.0 log with a
+// rule for each checktype and summary.
+func writeSARIF(w io.Writer, data *reportData) error {
+	rules := []sarifRule{}
+	ruleIndexes := map[string]int{}
+	results := []sarifResult{}
+	for _, s := range severities {
+		for i := range data.vulns {
+			v := &data.vulns[i]
+			if v.Severity.Name != s.Name || !v.isReported(data.threshold) {
+				continue
+			}
+			// Vulnerabilities are iterated by severity, so the rule takes the score of the most severe one.
+			ruleID := fmt.Sprintf("%s/%s", v.ChecktypeName, v.Summary)
+			index, ok := ruleIndexes[ruleID]
+			if !ok {
+				index = len(rules)
+				ruleIndexes[ruleID] = index
+				rules = append(rules, sarifRuleFor(ruleID, v))
+			}
+			result := sarifResult{
+				RuleID:    ruleID,
+				RuleIndex: index,
+				Level:     sarifLevels[v.Severity.Name],
+				Message:   sarifMessage{Text: v.Summary},
+				Locations: []sarifLocation{{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: sarifURI(v)},
+					},
+				}},
+			}
+			if v.Fingerprint != "" {
+				result.PartialFingerprints = map[string]string{"vulcanFingerprint/v1": v.Fingerprint}
+			}
+			results = append(results, result)
+		}
+	}
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{
+				Driver: sarifDriver{
+					Name:  toolName,
+					Rules: rules,
+				},
+			},
+			Results: results,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+func sarifRuleFor(id string, v *ExtendedVulnerability) sarifRule {
+	rule := sarifRule{
+		ID:               id,
+		Name:             v.Summary,
+		ShortDescription: sarifMessage{Text: v.Summary},
+		Properties: sarifRuleProperties{
+			SecuritySeverity: fmt.Sprintf("%.1f", v.Score),
+			Tags:             []string{"security", v.ChecktypeName},
+		},
+	}
+	if v.Description != "" {
+		rule.FullDescription = &sarifMessage{Text: v.Description}
+	}
+	if len(v.References) != 0 && v.References[0] != "" {
+		rule.HelpURI = v.References[0]
+	}
+	return rule
+}
+
+// sarifURI returns the location of the vulnerability, the affected resource
+// when present or the target otherwise.
+func sarifURI(v *ExtendedVulnerability) string {
+	if v.AffectedResource != "" {
+		return v.AffectedResource
+	}
+	return v.Target
+}
@@ -32,6 +32,7 @@ type LogsPayload struct {
 type ResultsServer struct {
 	Endpoint string
 	Checks   map[string]*report.Report
+	Logs     map[string][]byte
 	done     chan error
 	server   *http.Server
 	log      log.Logger
@@ -51,6 +52,7 @@ func Start(l log.Logger) (*ResultsServer, error) {
 	r := ResultsServer{
 		Endpoint: endpoint,
 		Checks:   make(map[string]*report.Report),
+		Logs:     make(map[string][]byte),
 		done:     make(chan error),
 		log:      l,
 	}
@@ -140,8 +142,19 @@ func (srv *ResultsServer) handleLogs(w http.ResponseWriter, r *http.Request, ps
 		return
 	}
 
-	sDec, _ := base64.StdEncoding.DecodeString(pl.B64Logs)
-	srv.log.Debugf(string(sDec))
+	logs, err := base64.StdEncoding.DecodeString(pl.B64Logs)
+	if err != nil {
+		srv.log.Errorf("Unable to decode logs of check %s %v", pl.CheckId, err)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	srv.log.Debugf("%s", logs)
+
+	srv.mu.Lock()
+	srv.Logs[pl.CheckId] = logs
+	srv.mu.Unlock()
 
 	w.Header().Add("location", "http://dummy/raw/"+pl.CheckId)
 	w.WriteHeader(http.StatusCreated)
@@ -0,0 +1,130 @@
+/*
+Copyright 2021 Adevinta
+*/
+
+package sqsservice
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	agentconfig "github.com/adevinta/vulcan-agent/config"
+	"github.com/adevinta/vulcan-agent/log"
+	"github.com/adevinta/vulcan-agent/queue"
+	"github.com/adevinta/vulcan-agent/queue/sqs"
+	"github.com/adevinta/vulcan-agent/stateupdater"
+)
+
+// CheckProgress is the state of a check read from the Status queue.
+type CheckProgress struct {
+	Status   string
+	Progress float32
+	Start    time.Time // First time the check was seen running
+	End      time.Time // Time the check reached a terminal status
+}
+
+// Terminal returns true if the check ended.
+func (p CheckProgress) Terminal() bool {
+	_, ok := stateupdater.TerminalStatuses[p.Status]
+	return ok
+}
+
+// StatusTracker processes the messages of the Status queue keeping the last
+// state of each check.
+type StatusTracker struct {
+	mu     sync.Mutex
+	checks map[string]CheckProgress
+	tokens chan interface{}
+	log    log.Logger
+}
+
+// NewStatusTracker returns a tracker without statuses.
+func NewStatusTracker(l log.Logger) *StatusTracker {
+	tokens := make(chan interface{}, 1)
+	tokens <- struct{}{}
+	return &StatusTracker{
+		checks: map[string]CheckProgress{},
+		tokens: tokens,
+		log:    l,
+	}
+}
+
+func (t *StatusTracker) FreeTokens() chan interface{} {
+	return t.tokens
+}
+
+func (t *StatusTracker) ProcessMessage(msg queue.Message, token interface{}) <-chan bool {
+	processed := make(chan bool, 1)
+	state := stateupdater.CheckState{}
+	if err := json.Unmarshal([]byte(msg.Body), &state); err != nil {
+		t.log.Errorf("Unable to decode check state %s %+v", msg.Body, err)
+	} else {
+		t.update(state)
+	}
+	processed <- true
+	t.tokens <- token
+	return processed
+}
+
+// update applies the state to the check unless it already ended.
+func (t *StatusTracker) update(state stateupdater.CheckState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.checks[state.ID]
+	if p.Terminal() {
+		return
+	}
+	if state.Status != nil {
+		p.Status = *state.Status
+	}
+	if state.Progress != nil {
+		p.Progress = *state.Progress
+	}
+	now := time.Now()
+	if p.Start.IsZero() && p.Status == stateupdater.StatusRunning {
+		p.Start = now
+	}
+	if p.Terminal() {
+		p.End = now
+	}
+	t.log.Debugf("Check state id=%s status=%s progress=%v", state.ID, p.Status, p.Progress)
+	t.checks[state.ID] = p
+}
+
+// Checks returns the state of the checks by check id.
+func (t *StatusTracker) Checks() map[string]CheckProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	checks := map[string]CheckProgress{}
+	for k, v := range t.checks {
+		checks[k] = v
+	}
+	return checks
+}
+
+// Statuses returns the last status of the checks by check id.
+func (t *StatusTracker) Statuses() map[string]string {
+	statuses := map[string]string{}
+	for k, v := range t.Checks() {
+		statuses[k] = v.Status
+	}
+	return statuses
+}
+
+// ReadStatus reads the Status queue with the tracker until the context is
+// canceled.
+func (s *SQSServer) ReadStatus(ctx context.Context, t *StatusTracker, l log.Logger) (<-chan error, error) {
+	r, err := sqs.NewReader(l, agentconfig.SQSReader{
+		Endpoint:          s.Endpoint,
+		ARN:               s.ArnStatus,
+		PollingInterval:   1,
+		VisibilityTimeout: 30,
+		ProcessQuantum:    10,
+	}, nil, t)
+	if err != nil {
+		return nil, err
+	}
+	return r.StartReading(ctx), nil
+}